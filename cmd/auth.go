@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/manager"
+	"github.com/spf13/cobra"
+)
+
+// tokenEnvVar contains the TSS API token, e.g. in CI pipelines.
+const tokenEnvVar = "ESI_TOKEN"
+
+// authFlags contains the flags of all commands that authenticate against the secret server.
+type authFlags struct {
+	nonInteractive bool
+	tokenFile      string
+	tokenStdin     bool
+}
+
+func (f *authFlags) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.nonInteractive, "non-interactive", false, "fail instead of prompting for credentials or an injector")
+	cmd.Flags().StringVar(&f.tokenFile, "token-file", "", "read the TSS API token from this file instead of the keyring")
+	cmd.Flags().BoolVar(&f.tokenStdin, "token-stdin", false, "read the TSS API token from stdin instead of the keyring")
+	cmd.MarkFlagsMutuallyExclusive("token-file", "token-stdin")
+}
+
+// opts returns the manager options of the flags.
+// The token is read from the token flags or the ESI_TOKEN env var, in that order.
+func (f *authFlags) opts() []manager.Opt {
+	opts := []manager.Opt{manager.WithNonInteractive(f.nonInteractive)}
+
+	var token string
+	switch {
+	case f.tokenFile != "":
+		data, err := os.ReadFile(f.tokenFile)
+		if err != nil {
+			log.Fatal("Failed to read token file", "err", err)
+		}
+		token = strings.TrimSpace(string(data))
+	case f.tokenStdin:
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatal("Failed to read token from stdin", "err", err)
+		}
+		token = strings.TrimSpace(string(data))
+	default:
+		token = strings.TrimSpace(os.Getenv(tokenEnvVar))
+		if token != "" {
+			log.Debug("Loaded token from env var", "var", tokenEnvVar)
+		}
+	}
+	// the command must never inherit the token
+	os.Unsetenv(tokenEnvVar) // nolint:errcheck
+
+	if token == "" && (f.tokenFile != "" || f.tokenStdin) {
+		log.Fatal("The token must not be empty")
+	}
+	if token != "" {
+		opts = append(opts, manager.WithToken(token))
+	}
+	return opts
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"os/user"
+
+	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/tmpfile"
+	"github.com/spf13/cobra"
+)
+
+var cleanupCmdFlags struct {
+	debug bool
+}
+
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup",
+	Short: "Remove tmp files left behind by esi runs that didn't exit properly",
+	Args:  cobra.NoArgs,
+	Run:   runCleanup,
+}
+
+func init() {
+	cleanupCmd.Flags().BoolVar(&cleanupCmdFlags.debug, "debug", false, "enable debug logs")
+}
+
+func runCleanup(_ *cobra.Command, _ []string) {
+	if cleanupCmdFlags.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	u, err := user.Current()
+	if err != nil {
+		log.Fatal("Failed to get current user", "err", err)
+	}
+
+	removed, err := tmpfile.CleanupStale(u.Uid)
+	for _, path := range removed {
+		log.Info("Cleanup successful!", "path", path)
+	}
+	if err != nil {
+		log.Fatal("Cleanup failed!", "err", err)
+	}
+	if len(removed) == 0 {
+		log.Info("Nothing to clean up.")
+	}
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/config"
+	"github.com/spf13/cobra"
+)
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the config of esi",
+}
+
+var configShowCmdFlags struct {
+	path   string
+	debug  bool
+	origin bool
+}
+
+var configShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the config merged from all config files",
+	Args:  cobra.NoArgs,
+	Run:   runConfigShow,
+	Example: `esi config show
+esi config show --origin`,
+}
+
+var configValidateCmdFlags struct {
+	path  string
+	debug bool
+}
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check the config for unknown keys, unknown secret ids, invalid templates and other mistakes",
+	Args:  cobra.NoArgs,
+	Run:   runConfigValidate,
+	Example: `esi config validate
+esi config validate -c ./esi.yml`,
+}
+
+func init() {
+	configShowCmd.Flags().StringVarP(&configShowCmdFlags.path, "config", "c", "", "path to the config file")
+	configShowCmd.Flags().BoolVar(&configShowCmdFlags.debug, "debug", false, "enable debug logs")
+	configShowCmd.Flags().BoolVar(&configShowCmdFlags.origin, "origin", false, "annotate every value with the file and line it was set in")
+
+	configValidateCmd.Flags().StringVarP(&configValidateCmdFlags.path, "config", "c", "", "path to the config file")
+	configValidateCmd.Flags().BoolVar(&configValidateCmdFlags.debug, "debug", false, "enable debug logs")
+
+	configCmd.AddCommand(configShowCmd, configValidateCmd)
+}
+
+func runConfigShow(_ *cobra.Command, _ []string) {
+	if configShowCmdFlags.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	src, err := config.LoadSource(configShowCmdFlags.path)
+	if err != nil {
+		log.Fatal("Failed to load config", "err", err)
+	}
+	// an invalid config is printed anyway, so that the mistakes can be found
+	if _, err := src.Config(); err != nil {
+		var verr *config.ValidationError
+		if !errors.As(err, &verr) {
+			log.Fatal("Failed to load config", "err", err)
+		}
+		log.Warn("The config is invalid, run esi config validate for details", "problems", len(verr.Problems))
+	}
+
+	out, err := src.YAML(configShowCmdFlags.origin)
+	if err != nil {
+		log.Fatal("Failed to print config", "err", err)
+	}
+	if configShowCmdFlags.origin {
+		for _, f := range src.Files {
+			fmt.Printf("# loaded %s\n", f)
+		}
+	}
+	os.Stdout.Write(out) // nolint:errcheck
+}
+
+func runConfigValidate(_ *cobra.Command, _ []string) {
+	if configValidateCmdFlags.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	src, err := config.LoadSource(configValidateCmdFlags.path)
+	if err != nil {
+		log.Fatal("Failed to load config", "err", err)
+	}
+	_, err = src.Config()
+	var verr *config.ValidationError
+	if errors.As(err, &verr) {
+		for _, p := range verr.Problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		log.Fatal("The config is invalid", "problems", len(verr.Problems))
+	}
+	if err != nil {
+		log.Fatal("Failed to load config", "err", err)
+	}
+	log.Info("The config is valid", "files", src.Files)
+}
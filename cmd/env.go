@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/config"
+	"github.com/jon4hz/esi/manager"
+	"github.com/jon4hz/esi/shell"
+	"github.com/jon4hz/esi/workspace"
+	"github.com/spf13/cobra"
+)
+
+var envCmdFlags struct {
+	path     string
+	injector []string
+	debug    bool
+	shell    string
+	unset    bool
+	auth     authFlags
+}
+
+var envCmd = &cobra.Command{
+	Use:   "env",
+	Short: "Print shell code that exports the env vars of an injector to the current shell",
+	Args:  cobra.NoArgs,
+	Run:   runEnv,
+	Example: `eval "$(esi env)"
+eval "$(esi env --unset)"
+esi env --shell=fish | source`,
+}
+
+func init() {
+	envCmd.Flags().StringVarP(&envCmdFlags.path, "config", "c", "", "path to the config file")
+	envCmd.Flags().StringSliceVar(&envCmdFlags.injector, "injector", nil, fmt.Sprintf("fqdn of the injector, repeat to merge multiple injectors (loads value from %s by default)", workspace.ESIWorkspaceFileName))
+	envCmd.Flags().BoolVar(&envCmdFlags.debug, "debug", false, "enable debug logs")
+	envCmd.Flags().StringVar(&envCmdFlags.shell, "shell", "", fmt.Sprintf("shell to generate code for %v (detected from $SHELL by default)", shell.Shells))
+	envCmd.Flags().BoolVar(&envCmdFlags.unset, "unset", false, "unset the env vars of the injector instead")
+	envCmdFlags.auth.register(envCmd)
+}
+
+func runEnv(cmd *cobra.Command, _ []string) {
+	if envCmdFlags.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	sh := shell.Detect()
+	if envCmdFlags.shell != "" {
+		var err error
+		if sh, err = shell.Parse(envCmdFlags.shell); err != nil {
+			log.Fatal("Invalid shell", "err", err)
+		}
+	}
+
+	cfg, err := config.Load(envCmdFlags.path)
+	if err != nil {
+		log.Fatal("Failed to load config", "err", err)
+	}
+
+	inj := lookupInjector(cmd, cfg, envCmdFlags.injector)
+
+	mgr, err := manager.New(cfg, nil, inj, envCmdFlags.auth.opts()...)
+	if err != nil {
+		log.Fatal("Failed to create manager", "err", err)
+	}
+
+	if envCmdFlags.unset {
+		keys, err := mgr.EnvKeys()
+		if err != nil {
+			log.Fatal("Failed to get env keys", "err", err)
+		}
+		lines := make([]string, 0, len(keys))
+		for _, k := range keys {
+			line, err := shell.Unset(sh, k)
+			if err != nil {
+				log.Fatal("Failed to unset env var", "err", err)
+			}
+			lines = append(lines, line)
+		}
+		printLines(lines)
+		return
+	}
+
+	vars, err := mgr.Env()
+	if err != nil {
+		log.Fatal("Failed to get env vars", "err", err)
+	}
+	lines := make([]string, 0, len(vars))
+	for _, v := range vars {
+		line, err := shell.Export(sh, v.Key, v.Value)
+		if err != nil {
+			log.Fatal("Failed to export env var", "err", err)
+		}
+		lines = append(lines, line)
+	}
+	printLines(lines)
+}
+
+// printLines prints the generated code. It's only printed once all lines were generated,
+// so that an invalid env var doesn't leave a partial script for eval.
+func printLines(lines []string) {
+	for _, line := range lines {
+		fmt.Println(line)
+	}
+}
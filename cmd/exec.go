@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/manager"
+	"github.com/spf13/cobra"
+)
+
+// execFlags contains the flags of all commands that execute a command.
+type execFlags struct {
+	mask        bool
+	noPTY       bool
+	watch       bool
+	interval    time.Duration
+	watchSignal string
+	timeout     time.Duration
+	exec        bool
+}
+
+func (f *execFlags) register(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&f.mask, "mask", false, "mask secret values in the output of the command")
+	cmd.Flags().BoolVar(&f.noPTY, "no-pty", false, "don't allocate a pseudo terminal for the command, if its output is masked")
+	cmd.Flags().BoolVar(&f.watch, "watch", false, "fetch the secrets periodically and restart the command if they changed")
+	cmd.Flags().DurationVar(&f.interval, "interval", 5*time.Minute, "interval in which the secrets are fetched in watch mode")
+	cmd.Flags().StringVar(&f.watchSignal, "watch-signal", "", "send this signal (e.g. SIGHUP) instead of restarting the command in watch mode")
+	cmd.Flags().DurationVar(&f.timeout, "timeout", 0, "terminate the command and remove its tmp files after this duration (e.g. 1h)")
+	cmd.Flags().BoolVar(&f.exec, "exec", false, "replace esi with the command, if esi doesn't have to cleanup tmp files, mask, watch or enforce a timeout")
+}
+
+// opts returns the manager options of the flags.
+func (f *execFlags) opts() []manager.Opt {
+	opts := []manager.Opt{manager.WithMask(f.mask), manager.WithPTY(!f.noPTY), manager.WithExec(f.exec)}
+	if f.timeout < 0 {
+		log.Fatal("The timeout must not be negative", "timeout", f.timeout)
+	}
+	opts = append(opts, manager.WithTimeout(f.timeout))
+	if f.watch {
+		if f.interval <= 0 {
+			log.Fatal("The watch interval must be positive", "interval", f.interval)
+		}
+		var sig os.Signal
+		if f.watchSignal != "" {
+			var err error
+			if sig, err = manager.ParseSignal(f.watchSignal); err != nil {
+				log.Fatal("Invalid watch signal", "err", err)
+			}
+		}
+		opts = append(opts, manager.WithWatch(f.interval, sig))
+	}
+	return opts
+}
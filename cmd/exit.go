@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/manager"
+)
+
+// exitOnError terminates esi if the manager failed.
+// If the command itself failed, esi exits with the same exit code.
+func exitOnError(err error) {
+	if err == nil {
+		return
+	}
+	var exitErr *manager.ExitError
+	if errors.As(err, &exitErr) {
+		if exitErr.Err != nil {
+			log.Error("Failed to start command", "err", exitErr.Err)
+		}
+		log.Debug("Command failed", "code", exitErr.Code)
+		os.Exit(exitErr.Code)
+	}
+	log.Fatal("Manager failed!", "err", err)
+}
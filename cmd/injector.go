@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/config"
+	"github.com/jon4hz/esi/workspace"
+	"github.com/spf13/cobra"
+)
+
+// lookupInjector returns the injectors set by the injector flags or the workspace file, merged into one.
+// If neither is set, nil is returned and the manager will ask the user to select them.
+func lookupInjector(cmd *cobra.Command, cfg *config.Config, fqdns []string) *config.Injector {
+	source := "injector flag"
+	if !cmd.Flags().Lookup("injector").Changed {
+		wscfg := workspace.New()
+		if wscfg == nil {
+			return nil
+		}
+		fqdns = wscfg.InjectorFQDNs()
+		source = "workspace file"
+	}
+	if len(fqdns) == 0 {
+		return nil
+	}
+
+	injectors := make([]*config.Injector, 0, len(fqdns))
+	for _, fqdn := range fqdns {
+		inj := cfg.InjectorByFQDN(fqdn)
+		if inj == nil {
+			log.Fatal("Unknown injector", "injector", fqdn, "source", source)
+		}
+		log.Debug("Loaded injector fqdn from "+source, "fqdn", fqdn)
+		injectors = append(injectors, inj)
+	}
+	inj, err := config.MergeInjectors(injectors...)
+	if err != nil {
+		log.Fatal("Failed to merge injectors", "err", err)
+	}
+	return inj
+}
@@ -15,7 +15,9 @@ import (
 var rootCmdFlags struct {
 	path     string
 	debug    bool
-	injector string
+	exec     execFlags
+	injector []string
+	auth     authFlags
 }
 
 var rootCmd = &cobra.Command{
@@ -30,13 +32,20 @@ var rootCmd = &cobra.Command{
 
 func init() {
 	rootCmd.Flags().StringVarP(&rootCmdFlags.path, "config", "c", "", "path to the config file")
-	rootCmd.Flags().StringVar(&rootCmdFlags.injector, "injector", "", fmt.Sprintf("fqdn of the injector (loads value from %s by default)", workspace.ESIWorkspaceFileName))
+	rootCmd.Flags().StringSliceVar(&rootCmdFlags.injector, "injector", nil, fmt.Sprintf("fqdn of the injector, repeat to merge multiple injectors (loads value from %s by default)", workspace.ESIWorkspaceFileName))
 	rootCmd.Flags().BoolVar(&rootCmdFlags.debug, "debug", false, "enable debug logs")
+	rootCmdFlags.exec.register(rootCmd)
+	rootCmdFlags.auth.register(rootCmd)
 
 	rootCmd.AddCommand(
 		versionCmd,
 		shellCmd,
 		loginCmd,
+		envCmd,
+		cleanupCmd,
+		watchdogCmd,
+		runCmd,
+		configCmd,
 	)
 }
 
@@ -55,26 +64,12 @@ func runRoot(cmd *cobra.Command, args []string) {
 		log.Fatal("Failed to load config", "err", err)
 	}
 
-	var inj *config.Injector
-	if cmd.Flags().Lookup("injector").Changed {
-		if inj = cfg.InjectorByFQDN(rootCmdFlags.injector); inj != nil {
-			log.Debug("Loaded injector fqdn from injector flag.", "fqdn", rootCmdFlags.injector)
-		}
-	} else {
-		wscfg := workspace.New()
-		if wscfg != nil {
-			if inj = cfg.InjectorByFQDN(wscfg.Injector); inj != nil {
-				log.Debug("Loaded injector fqdn from workspace file", "fqdn", wscfg.Injector)
-			}
-		}
-	}
+	inj := lookupInjector(cmd, cfg, rootCmdFlags.injector)
 
-	mgr, err := manager.New(cfg, args, inj)
+	mgr, err := manager.New(cfg, args, inj, append(rootCmdFlags.exec.opts(), rootCmdFlags.auth.opts()...)...)
 	if err != nil {
 		log.Fatal("Failed to create manager", "err", err)
 	}
 
-	if err := mgr.Run(false); err != nil {
-		log.Fatal("Manager failed!", "err", err)
-	}
+	exitOnError(mgr.Run(false))
 }
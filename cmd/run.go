@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/config"
+	"github.com/jon4hz/esi/manager"
+	"github.com/jon4hz/esi/workspace"
+	"github.com/spf13/cobra"
+)
+
+var runCmdFlags struct {
+	path    string
+	debug   bool
+	mask    bool
+	timeout time.Duration
+	auth    authFlags
+}
+
+var runCmd = &cobra.Command{
+	Use:   "run [process...]",
+	Short: fmt.Sprintf("Start the processes defined in the config or %s, each with its own injector", workspace.ESIWorkspaceFileName),
+	Run:   runRun,
+	Example: `esi run
+esi run api worker`,
+}
+
+func init() {
+	runCmd.Flags().StringVarP(&runCmdFlags.path, "config", "c", "", "path to the config file")
+	runCmd.Flags().BoolVar(&runCmdFlags.debug, "debug", false, "enable debug logs")
+	runCmd.Flags().BoolVar(&runCmdFlags.mask, "mask", false, "mask secret values in the output of the processes")
+	runCmd.Flags().DurationVar(&runCmdFlags.timeout, "timeout", 0, "terminate all processes and remove their tmp files after this duration (e.g. 1h)")
+	runCmdFlags.auth.register(runCmd)
+}
+
+func runRun(_ *cobra.Command, args []string) {
+	if runCmdFlags.debug {
+		log.SetLevel(log.DebugLevel)
+	}
+	if runCmdFlags.timeout < 0 {
+		log.Fatal("The timeout must not be negative", "timeout", runCmdFlags.timeout)
+	}
+
+	cfg, err := config.Load(runCmdFlags.path)
+	if err != nil {
+		log.Fatal("Failed to load config", "err", err)
+	}
+
+	// processes of the workspace take precedence over the ones of the config
+	processes := cfg.Processes
+	if wscfg := workspace.New(); wscfg != nil && len(wscfg.Processes) > 0 {
+		log.Debug("Loaded processes from workspace file")
+		processes = wscfg.Processes
+	}
+
+	selected, err := selectProcesses(processes, args)
+	if err != nil {
+		log.Fatal("Failed to select processes", "err", err)
+	}
+
+	procs := make([]*manager.Proc, 0, len(selected))
+	for _, p := range selected {
+		fqdns := p.InjectorFQDNs()
+		if len(fqdns) == 0 {
+			log.Fatal("The process has no injector", "process", p.Name)
+		}
+		injectors := make([]*config.Injector, 0, len(fqdns))
+		for _, fqdn := range fqdns {
+			inj := cfg.InjectorByFQDN(fqdn)
+			if inj == nil {
+				log.Fatal("Unknown injector", "process", p.Name, "injector", fqdn)
+			}
+			injectors = append(injectors, inj)
+		}
+		inj, err := config.MergeInjectors(injectors...)
+		if err != nil {
+			log.Fatal("Failed to merge injectors", "process", p.Name, "err", err)
+		}
+		procs = append(procs, &manager.Proc{Name: p.Name, Command: p.Command, Injector: inj})
+	}
+
+	opts := append(runCmdFlags.auth.opts(),
+		manager.WithMask(runCmdFlags.mask),
+		manager.WithTimeout(runCmdFlags.timeout),
+	)
+	exitOnError(manager.RunAll(cfg, procs, opts...))
+}
+
+// selectProcesses returns the processes with the given names in the order they are defined.
+// If no names are given, all processes are returned.
+func selectProcesses(processes []*config.Process, names []string) ([]*config.Process, error) {
+	if len(processes) == 0 {
+		return nil, fmt.Errorf("no processes defined in the config or %s", workspace.ESIWorkspaceFileName)
+	}
+	seen := make(map[string]bool, len(processes))
+	for _, p := range processes {
+		if p.Name == "" || p.Command == "" {
+			return nil, fmt.Errorf("process %q requires a name and a command", p.Name)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("process %q is defined multiple times", p.Name)
+		}
+		seen[p.Name] = true
+	}
+	if len(names) == 0 {
+		return processes, nil
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		if !seen[name] {
+			return nil, fmt.Errorf("unknown process %q", name)
+		}
+		wanted[name] = true
+	}
+	selected := make([]*config.Process, 0, len(wanted))
+	for _, p := range processes {
+		if wanted[p.Name] {
+			selected = append(selected, p)
+		}
+	}
+	return selected, nil
+}
@@ -13,8 +13,12 @@ import (
 
 var shellCmdFlags struct {
 	path     string
-	injector string
+	injector []string
 	debug    bool
+	quote    bool
+	raw      bool
+	exec     execFlags
+	auth     authFlags
 }
 
 var shellCmd = &cobra.Command{
@@ -28,8 +32,13 @@ var shellCmd = &cobra.Command{
 
 func init() {
 	shellCmd.Flags().StringVarP(&shellCmdFlags.path, "config", "c", "", "path to the config file")
-	shellCmd.Flags().StringVar(&shellCmdFlags.injector, "injector", "", fmt.Sprintf("fqdn of the injector (loads value from %s by default)", workspace.ESIWorkspaceFileName))
+	shellCmd.Flags().StringSliceVar(&shellCmdFlags.injector, "injector", nil, fmt.Sprintf("fqdn of the injector, repeat to merge multiple injectors (loads value from %s by default)", workspace.ESIWorkspaceFileName))
 	shellCmd.Flags().BoolVar(&shellCmdFlags.debug, "debug", false, "enable debug logs")
+	shellCmd.Flags().BoolVar(&shellCmdFlags.quote, "quote", false, "quote every arg, so that the shell passes it verbatim to the command")
+	shellCmd.Flags().BoolVar(&shellCmdFlags.raw, "raw", false, "pass the args unmodified to the shell, instead of escaping quotes and backslashes")
+	shellCmd.MarkFlagsMutuallyExclusive("quote", "raw")
+	shellCmdFlags.exec.register(shellCmd)
+	shellCmdFlags.auth.register(shellCmd)
 }
 
 func runShell(cmd *cobra.Command, args []string) {
@@ -42,26 +51,14 @@ func runShell(cmd *cobra.Command, args []string) {
 		log.Fatal("Failed to load config", "err", err)
 	}
 
-	var inj *config.Injector
-	if cmd.Flags().Lookup("injector").Changed {
-		if inj = cfg.InjectorByFQDN(shellCmdFlags.injector); inj != nil {
-			log.Debug("Loaded injector fqdn from injector flag.", "fqdn", shellCmdFlags.injector)
-		}
-	} else {
-		wscfg := workspace.New()
-		if wscfg != nil {
-			if inj = cfg.InjectorByFQDN(wscfg.Injector); inj != nil {
-				log.Debug("Loaded injector fqdn from workspace file", "fqdn", wscfg.Injector)
-			}
-		}
-	}
+	inj := lookupInjector(cmd, cfg, shellCmdFlags.injector)
 
-	mgr, err := manager.New(cfg, args, inj)
+	opts := append(shellCmdFlags.exec.opts(), shellCmdFlags.auth.opts()...)
+	opts = append(opts, manager.WithQuotedArgs(shellCmdFlags.quote), manager.WithRawArgs(shellCmdFlags.raw))
+	mgr, err := manager.New(cfg, args, inj, opts...)
 	if err != nil {
 		log.Fatal("Failed to create manager", "err", err)
 	}
 
-	if err := mgr.Run(true); err != nil {
-		log.Fatal("Manager failed!", "err", err)
-	}
+	exitOnError(mgr.Run(true))
 }
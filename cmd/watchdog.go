@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/jon4hz/esi/tmpfile"
+	"github.com/spf13/cobra"
+)
+
+var watchdogCmd = &cobra.Command{
+	Use:    "watchdog <run dir>",
+	Short:  "Remove the tmp files of a run once all its processes are gone",
+	Hidden: true,
+	Args:   cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		signal.Ignore(os.Interrupt, syscall.SIGHUP)
+		return tmpfile.Watch(args[0], os.Stdin)
+	},
+}
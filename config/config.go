@@ -1,12 +1,11 @@
 package config
 
 import (
-	"errors"
+	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
+	"time"
 
-	"github.com/adrg/xdg"
 	"github.com/charmbracelet/log"
 	"github.com/spf13/viper"
 )
@@ -15,6 +14,26 @@ type Config struct {
 	SecretServer *SecretServer `mapstructure:"secret_server"`
 	Secrets      []*Secret     `mapstructure:"secrets"`
 	Groups       []*Group      `mapstructure:"groups"`
+	Watchdog     bool          `mapstructure:"watchdog"`
+	Processes    []*Process    `mapstructure:"processes"`
+	Snippets     []*Snippet    `mapstructure:"snippets"`
+}
+
+// Process is a command started by esi run. The command is executed in a subshell.
+type Process struct {
+	Name      string   `mapstructure:"name" yaml:"name"`
+	Command   string   `mapstructure:"command" yaml:"command"`
+	Injector  string   `mapstructure:"injector" yaml:"injector"`
+	Injectors []string `mapstructure:"injectors" yaml:"injectors"`
+}
+
+// InjectorFQDNs returns the fqdns of all injectors of the process.
+func (p *Process) InjectorFQDNs() []string {
+	var fqdns []string
+	if p.Injector != "" {
+		fqdns = append(fqdns, p.Injector)
+	}
+	return append(fqdns, p.Injectors...)
 }
 
 type SecretServer struct {
@@ -23,10 +42,42 @@ type SecretServer struct {
 }
 
 type Secret struct {
-	ID       string `mapstructure:"id"`
-	Value    string `mapstructure:"-"`
-	SecretID int    `mapstructure:"secret_id"`
-	Field    string `mapstructure:"field"`
+	ID         string       `mapstructure:"id"`
+	Value      string       `mapstructure:"-"`
+	SecretID   int          `mapstructure:"secret_id"`
+	Field      string       `mapstructure:"field"`
+	Transforms []*Transform `mapstructure:"transforms"`
+}
+
+// Transform modifies the value of a secret after it was fetched.
+// Each transform must set exactly one option.
+type Transform struct {
+	JSONPath     string `mapstructure:"jsonpath"`
+	Base64Decode bool   `mapstructure:"base64decode"`
+	Trim         bool   `mapstructure:"trim"`
+	Prefix       string `mapstructure:"prefix"`
+	Suffix       string `mapstructure:"suffix"`
+}
+
+// Options returns the names of the options the transform sets.
+func (t *Transform) Options() []string {
+	var options []string
+	if t.JSONPath != "" {
+		options = append(options, "jsonpath")
+	}
+	if t.Base64Decode {
+		options = append(options, "base64decode")
+	}
+	if t.Trim {
+		options = append(options, "trim")
+	}
+	if t.Prefix != "" {
+		options = append(options, "prefix")
+	}
+	if t.Suffix != "" {
+		options = append(options, "suffix")
+	}
+	return options
 }
 
 type Group struct {
@@ -35,10 +86,43 @@ type Group struct {
 	Injectors []*Injector `mapstructure:"injectors"`
 }
 
+// Env modes of an injector. They define which env vars the command inherits from esi.
+const (
+	EnvModeInherit   = "inherit"
+	EnvModeClean     = "clean"
+	EnvModeAllowlist = "allowlist"
+)
+
 type Injector struct {
-	Name     string            `mapstructure:"name"`
-	Selected bool              `mapstructure:"selected"`
-	Configs  []*InjectorConfig `mapstructure:"configs"`
+	Name             string            `mapstructure:"name"`
+	Selected         bool              `mapstructure:"selected"`
+	Extends          string            `mapstructure:"extends"` // fqdn of the parent injector
+	Use              []*SnippetRef     `mapstructure:"use"`
+	AllowArgvSecrets bool              `mapstructure:"allow_argv_secrets"`
+	EnvMode          string            `mapstructure:"env_mode"`
+	EnvAllow         []string          `mapstructure:"env_allow"`
+	EnvDeny          []string          `mapstructure:"env_deny"`
+	Env              []string          `mapstructure:"env"` // static env vars in the form KEY=value
+	MaxRuntime       time.Duration     `mapstructure:"max_runtime"`
+	Configs          []*InjectorConfig `mapstructure:"configs"`
+}
+
+// Clone returns a deep copy of the injector and its configs.
+// The manager modifies the configs, so every manager running concurrently needs its own copy.
+func (i *Injector) Clone() *Injector {
+	cp := *i
+	cp.Use = append([]*SnippetRef(nil), i.Use...)
+	cp.EnvAllow = append([]string(nil), i.EnvAllow...)
+	cp.EnvDeny = append([]string(nil), i.EnvDeny...)
+	cp.Env = append([]string(nil), i.Env...)
+	cp.Configs = make([]*InjectorConfig, 0, len(i.Configs))
+	for _, c := range i.Configs {
+		cc := *c
+		cc.TmpFileSecrets = append([]string(nil), c.TmpFileSecrets...)
+		cc.Secrets = nil
+		cp.Configs = append(cp.Configs, &cc)
+	}
+	return &cp
 }
 
 type InjectorConfig struct {
@@ -49,77 +133,46 @@ type InjectorConfig struct {
 	Stdout       bool   `mapstructure:"stdout"`
 	StdoutSecret string `mapstructure:"stdout_secret"`
 	// Templated secrets
-	TmpFile        bool               `mapstructure:"tmp_file"`
-	TmpFileSecrets []string           `mapstructure:"tmp_file_secrets"`
-	TmpFileTmpl    string             `mapstructure:"tmp_file_tmpl"`
-	Secrets        map[string]*Secret `mapstructure:"-"` // helper struct for templates
-	TmpFileVar     string             `mapstructure:"tmp_file_var"`
-	TmpFileSuffix  string             `mapstructure:"tmp_file_suffix"`
-}
-
-func init() {
-	setDefaults()
+	TmpFile         bool               `mapstructure:"tmp_file"`
+	TmpFileSecrets  []string           `mapstructure:"tmp_file_secrets"`
+	TmpFileTmpl     string             `mapstructure:"tmp_file_tmpl"`
+	TmpFileTmplPath string             `mapstructure:"tmp_file_tmpl_path"`
+	Secrets         map[string]*Secret `mapstructure:"-"` // helper struct for templates
+	TmpFileVar      string             `mapstructure:"tmp_file_var"`
+	TmpFileSuffix   string             `mapstructure:"tmp_file_suffix"`
+	TmpFileDir      string             `mapstructure:"tmp_file_dir"`
+	TmpFileName     string             `mapstructure:"tmp_file_name"`
+	TmpFileMode     string             `mapstructure:"tmp_file_mode"`
+	TmpFilePath     string             `mapstructure:"tmp_file_path"`
+	TmpFileReadOnce bool               `mapstructure:"tmp_file_read_once"`
 }
 
-func setDefaults() {
-	//viper.SetDefault("secret_server.url", "https://my-secret-server.com")
-	viper.SetDefault("secret_server.ttl", 7200)
+func setDefaults(v *viper.Viper) {
+	//v.SetDefault("secret_server.url", "https://my-secret-server.com")
+	v.SetDefault("secret_server.ttl", 7200)
 }
 
-func Load(path string) (cfg *Config, err error) {
-	var loadedFrom string
-	if path != "" {
-		cfg, loadedFrom, err = loadCfg(path, true)
-		if err != nil {
-			return nil, err
-		}
-		log.Debug("Loaded config.", "path", loadedFrom, "flag", true)
-
-		return cfg, nil
-	}
-	for _, f := range &[...]string{
-		".esi.yml",
-		"esi.yml",
-		".esi.yaml",
-		"esi.yaml",
-	} {
-		cfg, loadedFrom, err = loadCfg(f, false)
-		if err != nil && os.IsNotExist(err) {
-			err = nil
-			continue
-		} else if err != nil && errors.As(err, &viper.ConfigFileNotFoundError{}) {
-			err = nil
-			continue
-		}
-		log.Debug("Loaded config.", "path", loadedFrom, "flag", false)
-		break
-	}
-
-	if cfg == nil {
-		return cfg, viper.Unmarshal(&cfg)
+// Load loads the config file at path. If path is empty, the system, user and project configs are merged.
+func Load(path string) (*Config, error) {
+	src, err := LoadSource(path)
+	if err != nil {
+		return nil, err
 	}
-
-	return
+	log.Debug("Loaded config.", "files", src.Files, "flag", path != "")
+	return src.Config()
 }
 
-func loadCfg(file string, explicit bool) (cfg *Config, loadedFrom string, err error) {
-	if explicit {
-		viper.SetConfigFile(file)
-	} else {
-		viper.SetConfigName(file)
-		viper.SetConfigType("yaml")
-		viper.AddConfigPath("./")
-		viper.AddConfigPath(filepath.Join(xdg.ConfigHome, "esi"))
-		viper.AddConfigPath("/etc/esi/")
-	}
-	if err = viper.ReadInConfig(); err != nil {
-		return
+// Template returns the template of the tmp file. If tmp_file_tmpl_path is set,
+// the template is loaded from that file.
+func (c *InjectorConfig) Template() (string, error) {
+	if c.TmpFileTmplPath == "" {
+		return c.TmpFileTmpl, nil
 	}
-	if err = viper.Unmarshal(&cfg); err != nil {
-		return
+	data, err := os.ReadFile(c.TmpFileTmplPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %w", err)
 	}
-	loadedFrom = viper.ConfigFileUsed()
-	return
+	return string(data), nil
 }
 
 func (c *Config) SecretByID(id string) *Secret {
@@ -143,16 +196,21 @@ func (c *Config) InjectorsByGroupName(name string) []*Injector {
 }
 
 func (c *Config) InjectorByFQDN(fqdn string) *Injector {
-	l := func(s string) string { return strings.ToLower(s) }
+	if i := c.findInjector(fqdn); i != nil {
+		return i
+	}
+	log.Error("Failed to find injector by fqdn", "fqdn", fqdn)
+	return nil
+}
+
+// findInjector returns the injector with the fqdn or nil, if there is none.
+func (c *Config) findInjector(fqdn string) *Injector {
 	for _, g := range c.Groups {
-		if strings.HasPrefix(l(fqdn), l(g.Name)) {
-			for _, i := range g.Injectors {
-				if strings.EqualFold(fqdn, g.Name+"."+i.Name) {
-					return i
-				}
+		for _, i := range g.Injectors {
+			if strings.EqualFold(fqdn, g.Name+"."+i.Name) {
+				return i
 			}
 		}
 	}
-	log.Error("Failed to find injector by fqdn", "fqdn", fqdn)
 	return nil
 }
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// Snippet is a named list of injector configs, which can be used by multiple injectors.
+// The configs may reference the params of the snippet as ${param}.
+type Snippet struct {
+	Name    string            `mapstructure:"name"`
+	Params  []string          `mapstructure:"params"`
+	Configs []*InjectorConfig `mapstructure:"configs"`
+}
+
+// SnippetRef adds the configs of a snippet to an injector.
+type SnippetRef struct {
+	Snippet string            `mapstructure:"snippet"`
+	With    map[string]string `mapstructure:"with"`
+}
+
+// paramPattern matches a reference to a param of a snippet.
+var paramPattern = regexp.MustCompile(`\$\{([A-Za-z0-9_-]+)\}`)
+
+// expand adds the configs of the snippets used by the injectors and resolves the inheritance of the injectors.
+func (c *Config) expand() error {
+	snippets := make(map[string]*Snippet, len(c.Snippets))
+	for _, s := range c.Snippets {
+		name := strings.ToLower(s.Name)
+		if _, ok := snippets[name]; ok {
+			return fmt.Errorf("snippet %q is defined multiple times", s.Name)
+		}
+		snippets[name] = s
+	}
+
+	// snippets first, so that their configs are inherited as well
+	for _, g := range c.Groups {
+		for _, inj := range g.Injectors {
+			configs, err := useSnippets(snippets, g.Name+"."+inj.Name, inj.Use)
+			if err != nil {
+				return err
+			}
+			inj.Configs = append(configs, inj.Configs...)
+		}
+	}
+
+	resolved := make(map[*Injector]bool)
+	for _, g := range c.Groups {
+		for _, inj := range g.Injectors {
+			if err := c.resolveExtends(inj, g.Name+"."+inj.Name, resolved, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// useSnippets returns copies of the configs of the snippets with all params replaced.
+func useSnippets(snippets map[string]*Snippet, fqdn string, refs []*SnippetRef) ([]*InjectorConfig, error) {
+	var configs []*InjectorConfig
+	for _, ref := range refs {
+		s, ok := snippets[strings.ToLower(ref.Snippet)]
+		if !ok {
+			return nil, fmt.Errorf("injector %q uses unknown snippet %q", fqdn, ref.Snippet)
+		}
+
+		declared := make(map[string]bool, len(s.Params))
+		for _, p := range s.Params {
+			declared[strings.ToLower(p)] = true
+		}
+		params := make(map[string]string, len(ref.With))
+		for k, v := range ref.With {
+			k = strings.ToLower(k)
+			if !declared[k] {
+				return nil, fmt.Errorf("injector %q passes unknown param %q to snippet %q", fqdn, k, s.Name)
+			}
+			params[k] = v
+		}
+		for _, p := range s.Params {
+			if _, ok := params[strings.ToLower(p)]; !ok {
+				return nil, fmt.Errorf("injector %q doesn't pass param %q to snippet %q", fqdn, p, s.Name)
+			}
+		}
+
+		for _, sc := range s.Configs {
+			ic := *sc
+			substitute(reflect.ValueOf(&ic).Elem(), params)
+			configs = append(configs, &ic)
+		}
+	}
+	return configs, nil
+}
+
+// substitute replaces the params in all string fields of the struct.
+// References to anything else than a param are kept, e.g. env vars in a template.
+func substitute(v reflect.Value, params map[string]string) {
+	replace := func(s string) string {
+		return paramPattern.ReplaceAllStringFunc(s, func(ref string) string {
+			if value, ok := params[strings.ToLower(paramPattern.FindStringSubmatch(ref)[1])]; ok {
+				return value
+			}
+			return ref
+		})
+	}
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		switch {
+		case f.Kind() == reflect.String:
+			f.SetString(replace(f.String()))
+		case f.Kind() == reflect.Slice && f.Type().Elem().Kind() == reflect.String && f.Len() > 0:
+			values := make([]string, f.Len())
+			for j := range values {
+				values[j] = replace(f.Index(j).String())
+			}
+			f.Set(reflect.ValueOf(values))
+		}
+	}
+}
+
+// resolveExtends merges the injector with the injector it extends. Parents are resolved first.
+// path contains the fqdns of the injectors extending this one, to detect cycles.
+func (c *Config) resolveExtends(inj *Injector, fqdn string, resolved map[*Injector]bool, path []string) error {
+	if resolved[inj] {
+		return nil
+	}
+	for _, p := range path {
+		if strings.EqualFold(p, fqdn) {
+			return fmt.Errorf("injectors extend each other: %s", strings.Join(append(path, fqdn), " -> "))
+		}
+	}
+	if inj.Extends != "" {
+		parent := c.findInjector(inj.Extends)
+		if parent == nil {
+			return fmt.Errorf("injector %q extends unknown injector %q", fqdn, inj.Extends)
+		}
+		if err := c.resolveExtends(parent, inj.Extends, resolved, append(path, fqdn)); err != nil {
+			return err
+		}
+		inherit(inj, parent)
+	}
+	resolved[inj] = true
+	return nil
+}
+
+// inherit merges the settings of the parent into the child. Settings of the child take precedence.
+// A config of the child replaces the config of the parent, which sets the same env var or tmp file var.
+func inherit(child, parent *Injector) {
+	if child.EnvMode == "" {
+		child.EnvMode = parent.EnvMode
+	}
+	if child.MaxRuntime == 0 {
+		child.MaxRuntime = parent.MaxRuntime
+	}
+	// allow_argv_secrets isn't inherited, every injector has to opt in itself
+	child.EnvAllow = append(append([]string{}, parent.EnvAllow...), child.EnvAllow...)
+	child.EnvDeny = append(append([]string{}, parent.EnvDeny...), child.EnvDeny...)
+	// static env vars of the child are set last, so they win
+	child.Env = append(append([]string{}, parent.Env...), child.Env...)
+
+	overridden := make(map[string]bool, len(child.Configs))
+	for _, ic := range child.Configs {
+		if key := configKey(ic); key != "" {
+			overridden[key] = true
+		}
+	}
+	configs := make([]*InjectorConfig, 0, len(parent.Configs)+len(child.Configs))
+	for _, ic := range parent.Configs {
+		if key := configKey(ic); key != "" && overridden[key] {
+			continue
+		}
+		cp := *ic
+		configs = append(configs, &cp)
+	}
+	child.Configs = append(configs, child.Configs...)
+}
+
+// configKey identifies what the config injects. Configs with the same key override each other.
+func configKey(ic *InjectorConfig) string {
+	switch {
+	case ic.EnvKey != "":
+		return "env:" + ic.EnvKey
+	case ic.TmpFile && ic.TmpFileVar != "":
+		return "tmp_file_var:" + ic.TmpFileVar
+	case ic.Stdout:
+		return "stdout"
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const expandConfig = `
+secrets:
+  - {id: dev-token, secret_id: 1}
+  - {id: dev-log, secret_id: 2}
+  - {id: dev-db, secret_id: 3}
+  - {id: api-token, secret_id: 4}
+snippets:
+  - name: database
+    params: [env, secret]
+    configs:
+      - env_key: ${env}_PASSWORD
+        env_secret: ${secret}
+      - tmp_file: true
+        tmp_file_var: PGPASSFILE
+        tmp_file_tmpl: '*:*:*:${USER}:{{ secret "${secret}" }}'
+groups:
+  - name: dev
+    injectors:
+      - name: base
+        env_mode: clean
+        max_runtime: 1h
+        allow_argv_secrets: true
+        env: [APP_ENV=dev]
+        configs:
+          - env_key: API_TOKEN
+            env_secret: dev-token
+          - env_key: LOG_LEVEL_SECRET
+            env_secret: dev-log
+      - name: api
+        extends: dev.base
+        env: [APP_NAME=api]
+        use:
+          - snippet: database
+            with:
+              env: DB
+              secret: dev-db
+        configs:
+          - env_key: API_TOKEN
+            env_secret: api-token
+`
+
+func TestExpand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "esi.yml")
+	require.NoError(t, os.WriteFile(path, []byte(expandConfig), 0o600))
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+
+	base, api := cfg.findInjector("dev.base"), cfg.findInjector("dev.api")
+	require.NotNil(t, api)
+	assert.Equal(t, EnvModeClean, api.EnvMode)
+	assert.Equal(t, time.Hour, api.MaxRuntime)
+	assert.Equal(t, []string{"APP_ENV=dev", "APP_NAME=api"}, api.Env)
+	assert.False(t, api.AllowArgvSecrets)
+	assert.True(t, base.AllowArgvSecrets)
+
+	require.Len(t, api.Configs, 4)
+	assert.Equal(t, &InjectorConfig{EnvKey: "LOG_LEVEL_SECRET", EnvSecret: "dev-log"}, api.Configs[0])
+	assert.Equal(t, &InjectorConfig{EnvKey: "DB_PASSWORD", EnvSecret: "dev-db"}, api.Configs[1])
+	assert.Equal(t, `*:*:*:${USER}:{{ secret "dev-db" }}`, api.Configs[2].TmpFileTmpl)
+	assert.Equal(t, &InjectorConfig{EnvKey: "API_TOKEN", EnvSecret: "api-token"}, api.Configs[3])
+
+	// the parent is unchanged
+	require.Len(t, base.Configs, 2)
+	assert.Equal(t, "dev-token", base.Configs[0].EnvSecret)
+	assert.NotSame(t, base.Configs[1], api.Configs[0])
+	assert.Equal(t, "${env}_PASSWORD", cfg.Snippets[0].Configs[0].EnvKey)
+}
+
+func TestExpandErrors(t *testing.T) {
+	type testCase struct {
+		name string
+		cfg  *Config
+		err  string
+	}
+
+	group := func(injectors ...*Injector) []*Group {
+		return []*Group{{Name: "dev", Injectors: injectors}}
+	}
+	snippets := []*Snippet{{Name: "db", Params: []string{"secret"}}}
+
+	testCases := []testCase{
+		{
+			name: "unknown parent",
+			cfg:  &Config{Groups: group(&Injector{Name: "api", Extends: "dev.base"})},
+			err:  `injector "dev.api" extends unknown injector "dev.base"`,
+		},
+		{
+			name: "cycle",
+			cfg: &Config{Groups: group(
+				&Injector{Name: "a", Extends: "dev.b"},
+				&Injector{Name: "b", Extends: "dev.c"},
+				&Injector{Name: "c", Extends: "dev.a"},
+			)},
+			err: `injectors extend each other: dev.a -> dev.b -> dev.c -> dev.a`,
+		},
+		{
+			name: "self",
+			cfg:  &Config{Groups: group(&Injector{Name: "a", Extends: "dev.a"})},
+			err:  `injectors extend each other: dev.a -> dev.a`,
+		},
+		{
+			name: "unknown snippet",
+			cfg:  &Config{Groups: group(&Injector{Name: "api", Use: []*SnippetRef{{Snippet: "cache"}}})},
+			err:  `injector "dev.api" uses unknown snippet "cache"`,
+		},
+		{
+			name: "unknown param",
+			cfg: &Config{Snippets: snippets, Groups: group(&Injector{Name: "api", Use: []*SnippetRef{
+				{Snippet: "db", With: map[string]string{"secret": "a", "user": "b"}},
+			}})},
+			err: `injector "dev.api" passes unknown param "user" to snippet "db"`,
+		},
+		{
+			name: "missing param",
+			cfg:  &Config{Snippets: snippets, Groups: group(&Injector{Name: "api", Use: []*SnippetRef{{Snippet: "db"}}})},
+			err:  `injector "dev.api" doesn't pass param "secret" to snippet "db"`,
+		},
+		{
+			name: "duplicate snippet",
+			cfg:  &Config{Snippets: append(snippets, &Snippet{Name: "DB"})},
+			err:  `snippet "DB" is defined multiple times`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.EqualError(t, tc.cfg.expand(), tc.err)
+		})
+	}
+}
@@ -0,0 +1,460 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/adrg/xdg"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// includeKey lists glob patterns of further config files. Relative patterns are relative to the including file.
+const includeKey = "include"
+
+// configNames are the names of the config file in each layer dir, in the order they are looked up.
+var configNames = []string{".esi.yml", "esi.yml", ".esi.yaml", "esi.yaml"}
+
+// mergeKeys are the lists, whose items are merged by the given key instead of being replaced.
+// This way, a layer can add injectors to a group of a lower layer.
+var mergeKeys = map[string]string{
+	"groups":    "name",
+	"injectors": "name",
+	"secrets":   "id",
+	"processes": "name",
+	"snippets":  "name",
+}
+
+// layerDirs returns the dirs searched for config files, from the lowest to the highest priority.
+var layerDirs = func() []string {
+	return []string{"/etc/esi", filepath.Join(xdg.ConfigHome, "esi"), "."}
+}
+
+// Source is the raw config merged from all loaded files.
+type Source struct {
+	// Files are the loaded config files, from the lowest to the highest priority.
+	Files []string
+	data  map[string]any
+	// origins maps the path of every value to the file and line it was set in
+	origins map[string]string
+	// nodes contains the yaml node of every value, which isn't merged any further
+	nodes map[string]*yaml.Node
+	// keys contains the keys of every mapping and the item keys of every merged list in the order they were added
+	keys map[string][]string
+	// lists contains the paths of the lists merged by key
+	lists   map[string]bool
+	visited map[string]bool
+	// problems found while loading the files
+	problems []Problem
+}
+
+// LoadSource loads the config file at path. If path is empty, the config files of the system,
+// the user and the project are merged in that order, so that later layers override earlier ones.
+// Files listed in include are merged before the file including them.
+func LoadSource(path string) (*Source, error) {
+	s := &Source{
+		data:    make(map[string]any),
+		origins: make(map[string]string),
+		nodes:   make(map[string]*yaml.Node),
+		keys:    make(map[string][]string),
+		lists:   make(map[string]bool),
+		visited: make(map[string]bool),
+	}
+	if path != "" {
+		return s, s.load(path)
+	}
+	for _, dir := range layerDirs() {
+		for _, name := range configNames {
+			file := filepath.Join(dir, name)
+			if _, err := os.Stat(file); err != nil {
+				continue
+			}
+			if err := s.load(file); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return s, nil
+}
+
+// Config decodes the merged config, validates and expands it.
+// If the config is invalid, a *ValidationError is returned.
+func (s *Source) Config() (*Config, error) {
+	v := viper.New()
+	setDefaults(v)
+	if err := v.MergeConfigMap(s.data); err != nil {
+		return nil, err
+	}
+	var cfg *Config
+	if err := v.Unmarshal(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	problems := append(append([]Problem{}, s.problems...), s.validate(cfg)...)
+	if len(problems) > 0 {
+		sortProblems(problems, s.Files)
+		return nil, &ValidationError{Problems: problems}
+	}
+	if err := cfg.expand(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Origin returns the file and line the value at path was set in, e.g. "groups[dev].injectors[api].configs[0]".
+// If the value wasn't set, the origin of the closest parent is returned.
+func (s *Source) Origin(path string) string {
+	for p := path; p != ""; p = parentPath(p) {
+		origin, ok := s.origins[p]
+		if !ok {
+			continue
+		}
+		// the value might be part of a list or mapping, which was set as a whole
+		if n, ok := s.nodes[p]; ok && p != path {
+			if n := walk(n, path[len(p):]); n != nil {
+				return fmt.Sprintf("%s:%d", origin[:strings.LastIndex(origin, ":")], n.Line)
+			}
+		}
+		return origin
+	}
+	return ""
+}
+
+// itemPath returns the path of the i-th item of the list at path.
+func (s *Source) itemPath(path string, i int) string {
+	if s.lists[path] && i < len(s.keys[path]) {
+		return fmt.Sprintf("%s[%s]", path, s.keys[path][i])
+	}
+	return fmt.Sprintf("%s[%d]", path, i)
+}
+
+// load merges the config file and the files it includes.
+func (s *Source) load(file string) error {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return err
+	}
+	// the same file might be included multiple times or be part of multiple layers
+	if s.visited[abs] {
+		return nil
+	}
+	s.visited[abs] = true
+
+	data, err := os.ReadFile(abs)
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", abs, err)
+	}
+	if len(doc.Content) == 0 {
+		s.Files = append(s.Files, abs)
+		return nil
+	}
+	root := resolveAlias(doc.Content[0])
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s:%d: expected a mapping", abs, root.Line)
+	}
+
+	// includes first, so that the including file overrides them
+	patterns, err := includePatterns(root)
+	if err != nil {
+		return fmt.Errorf("%s: %w", abs, err)
+	}
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(abs), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("%s: invalid include pattern %q: %w", abs, pattern, err)
+		}
+		for _, m := range matches {
+			if err := s.load(m); err != nil {
+				return err
+			}
+		}
+	}
+
+	s.Files = append(s.Files, abs)
+	s.checkKeys(root, reflect.TypeOf(Config{}), abs, true)
+	resolvePaths(root, filepath.Dir(abs))
+	return s.merge(s.data, root, "", abs)
+}
+
+// resolvePaths makes all relative template paths in the yaml node relative to the given dir.
+func resolvePaths(n *yaml.Node, dir string) {
+	if n.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			v := n.Content[i+1]
+			if strings.EqualFold(n.Content[i].Value, "tmp_file_tmpl_path") && v.Kind == yaml.ScalarNode &&
+				v.Value != "" && !filepath.IsAbs(v.Value) {
+				v.Value = filepath.Join(dir, v.Value)
+			}
+		}
+	}
+	for _, c := range n.Content {
+		resolvePaths(c, dir)
+	}
+}
+
+// includePatterns returns the include patterns of the config file.
+func includePatterns(root *yaml.Node) ([]string, error) {
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if !strings.EqualFold(root.Content[i].Value, includeKey) {
+			continue
+		}
+		var patterns []string
+		v := resolveAlias(root.Content[i+1])
+		if v.Kind == yaml.ScalarNode {
+			return []string{v.Value}, nil
+		}
+		if err := v.Decode(&patterns); err != nil {
+			return nil, fmt.Errorf("line %d: include must be a list of glob patterns", v.Line)
+		}
+		return patterns, nil
+	}
+	return nil, nil
+}
+
+// merge merges the yaml mapping into dst. Mappings are merged recursively, lists in mergeKeys are merged
+// by their key and all other values are replaced.
+func (s *Source) merge(dst map[string]any, src *yaml.Node, path, file string) error {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		k := strings.ToLower(src.Content[i].Value)
+		if path == "" && k == includeKey {
+			continue
+		}
+		v := resolveAlias(src.Content[i+1])
+		p := joinPath(path, k)
+		if _, ok := dst[k]; !ok {
+			s.keys[path] = append(s.keys[path], k)
+		}
+
+		switch {
+		case v.Kind == yaml.MappingNode:
+			child, ok := dst[k].(map[string]any)
+			if !ok {
+				s.clear(p)
+				child = make(map[string]any)
+				dst[k] = child
+				s.origins[p] = fmt.Sprintf("%s:%d", file, src.Content[i].Line)
+			}
+			if err := s.merge(child, v, p, file); err != nil {
+				return err
+			}
+			continue
+
+		case v.Kind == yaml.SequenceNode && keyedItems(v, mergeKeys[k]):
+			// a list, which wasn't merged by key, is replaced as a whole
+			list, ok := dst[k].([]any)
+			if !ok || !s.lists[p] {
+				s.clear(p)
+				list = nil
+			}
+			list, err := s.mergeList(list, v, mergeKeys[k], p, file)
+			if err != nil {
+				return err
+			}
+			dst[k] = list
+			continue
+		}
+
+		var value any
+		if err := v.Decode(&value); err != nil {
+			return fmt.Errorf("%s:%d: %w", file, v.Line, err)
+		}
+		s.clear(p)
+		dst[k] = value
+		s.nodes[p] = v
+		s.origins[p] = fmt.Sprintf("%s:%d", file, src.Content[i].Line)
+	}
+	return nil
+}
+
+// mergeList merges the items of the yaml sequence into the list. Items with the same key are merged.
+func (s *Source) mergeList(list []any, src *yaml.Node, key, path, file string) ([]any, error) {
+	s.lists[path] = true
+	for _, item := range src.Content {
+		item = resolveAlias(item)
+		name := mappingValue(item, key).Value
+
+		var target map[string]any
+		for i, existing := range list {
+			if m, ok := existing.(map[string]any); ok && strings.EqualFold(fmt.Sprint(m[key]), name) {
+				target = m
+				// keep the name the item was added with, so that its path doesn't change
+				if i < len(s.keys[path]) {
+					name = s.keys[path][i]
+				}
+				break
+			}
+		}
+		if target == nil {
+			target = make(map[string]any)
+			list = append(list, target)
+			s.keys[path] = append(s.keys[path], name)
+			s.origins[fmt.Sprintf("%s[%s]", path, name)] = fmt.Sprintf("%s:%d", file, item.Line)
+		}
+		if err := s.merge(target, item, fmt.Sprintf("%s[%s]", path, name), file); err != nil {
+			return nil, err
+		}
+	}
+	return list, nil
+}
+
+// clear removes everything known about the value at path and its children, because it's replaced.
+func (s *Source) clear(path string) {
+	for p := range s.origins {
+		if isChildPath(p, path) {
+			delete(s.origins, p)
+		}
+	}
+	for p := range s.nodes {
+		if isChildPath(p, path) {
+			delete(s.nodes, p)
+		}
+	}
+	for p := range s.keys {
+		if isChildPath(p, path) {
+			delete(s.keys, p)
+		}
+	}
+	for p := range s.lists {
+		if isChildPath(p, path) {
+			delete(s.lists, p)
+		}
+	}
+}
+
+// YAML returns the merged config. If origin is true, every value is annotated with the file and line it was set in.
+func (s *Source) YAML(origin bool) ([]byte, error) {
+	var b bytes.Buffer
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(s.node("", origin)); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// node builds the yaml node of the merged value at path.
+func (s *Source) node(path string, origin bool) *yaml.Node {
+	if n, ok := s.nodes[path]; ok {
+		return n
+	}
+	if s.lists[path] {
+		seq := &yaml.Node{Kind: yaml.SequenceNode}
+		for _, name := range s.keys[path] {
+			seq.Content = append(seq.Content, s.node(fmt.Sprintf("%s[%s]", path, name), origin))
+		}
+		return seq
+	}
+	m := &yaml.Node{Kind: yaml.MappingNode}
+	for _, k := range s.keys[path] {
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Value: k}
+		if origin {
+			// only values are annotated, mappings and lists are annotated item by item
+			if _, ok := s.nodes[joinPath(path, k)]; ok {
+				keyNode.LineComment = s.origins[joinPath(path, k)]
+			}
+		}
+		m.Content = append(m.Content, keyNode, s.node(joinPath(path, k), origin))
+	}
+	return m
+}
+
+// keyedItems reports whether all items of the sequence are mappings with a scalar value for key.
+func keyedItems(seq *yaml.Node, key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, item := range seq.Content {
+		item = resolveAlias(item)
+		if item.Kind != yaml.MappingNode {
+			return false
+		}
+		if v := mappingValue(item, key); v == nil || v.Kind != yaml.ScalarNode || v.Value == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// mappingValue returns the value of key in the mapping or nil.
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if strings.EqualFold(m.Content[i].Value, key) {
+			return resolveAlias(m.Content[i+1])
+		}
+	}
+	return nil
+}
+
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
+// parentPath returns the path of the list or mapping containing the value at path.
+func parentPath(path string) string {
+	if i := strings.LastIndexAny(path, ".["); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// walk returns the node at the relative path, e.g. "[1].env_secret", inside n or nil.
+func walk(n *yaml.Node, path string) *yaml.Node {
+	for path != "" && n != nil {
+		n = resolveAlias(n)
+		if path[0] == '[' {
+			end := strings.IndexByte(path, ']')
+			if end < 0 {
+				return nil
+			}
+			i, err := strconv.Atoi(path[1:end])
+			if err != nil || n.Kind != yaml.SequenceNode || i < 0 || i >= len(n.Content) {
+				return nil
+			}
+			n, path = n.Content[i], path[end+1:]
+			continue
+		}
+		path = strings.TrimPrefix(path, ".")
+		end := strings.IndexAny(path, ".[")
+		if end < 0 {
+			end = len(path)
+		}
+		if n.Kind != yaml.MappingNode {
+			return nil
+		}
+		n, path = mappingValue(n, path[:end]), path[end:]
+	}
+	return n
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// isChildPath reports whether p is path or one of its children.
+func isChildPath(p, path string) bool {
+	return p == path || strings.HasPrefix(p, path+".") || strings.HasPrefix(p, path+"[")
+}
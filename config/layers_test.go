@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o700))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestLoadSourceLayers(t *testing.T) {
+	dir := t.TempDir()
+	system, user, project := filepath.Join(dir, "etc"), filepath.Join(dir, "user"), filepath.Join(dir, "project")
+
+	writeFile(t, filepath.Join(system, "esi.yml"), `secret_server:
+  url: https://tss.example.com
+  ttl: 3600
+secrets:
+  - id: db
+    secret_id: 1
+    field: password
+groups:
+  - name: dev
+    injectors:
+      - name: api
+        configs:
+          - env_key: DB_PASSWORD
+            env_secret: db
+`)
+	writeFile(t, filepath.Join(user, "esi.yml"), `secret_server:
+  ttl: 600
+groups:
+  - name: DEV
+    injectors:
+      - name: worker
+        configs:
+          - env_key: WORKER_PASSWORD
+            env_secret: db
+`)
+	writeFile(t, filepath.Join(project, ".esi.yml"), `include: conf.d/*.yml
+groups:
+  - name: dev
+    injectors:
+      - name: api
+        env_mode: clean
+`)
+	writeFile(t, filepath.Join(project, "conf.d", "a.yml"), `groups:
+  - name: dev
+    injectors:
+      - name: api
+        env_mode: allowlist
+        configs:
+          - tmp_file: true
+            tmp_file_tmpl_path: tmpl/db.tmpl
+`)
+	writeFile(t, filepath.Join(project, "conf.d", "tmpl", "db.tmpl"), `{{ secret "db" }}`)
+
+	orig := layerDirs
+	layerDirs = func() []string { return []string{system, user, project} }
+	t.Cleanup(func() { layerDirs = orig })
+
+	src, err := LoadSource("")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(system, "esi.yml"),
+		filepath.Join(user, "esi.yml"),
+		filepath.Join(project, "conf.d", "a.yml"),
+		filepath.Join(project, ".esi.yml"),
+	}, src.Files)
+
+	cfg, err := src.Config()
+	require.NoError(t, err)
+	assert.Equal(t, "https://tss.example.com", cfg.SecretServer.URL)
+	assert.Equal(t, uint(600), cfg.SecretServer.TTL)
+	require.Len(t, cfg.Groups, 1)
+	require.Len(t, cfg.Groups[0].Injectors, 2)
+
+	api := cfg.findInjector("dev.api")
+	assert.Equal(t, EnvModeClean, api.EnvMode)
+	require.Len(t, api.Configs, 1)
+	assert.Equal(t, filepath.Join(project, "conf.d", "tmpl", "db.tmpl"), api.Configs[0].TmpFileTmplPath)
+	assert.NotNil(t, cfg.findInjector("dev.worker"))
+
+	assert.Equal(t, filepath.Join(system, "esi.yml")+":2", src.Origin("secret_server.url"))
+	assert.Equal(t, filepath.Join(user, "esi.yml")+":2", src.Origin("secret_server.ttl"))
+	assert.Equal(t, filepath.Join(project, ".esi.yml")+":6", src.Origin("groups[dev].injectors[api].env_mode"))
+	assert.Equal(t, filepath.Join(project, "conf.d", "a.yml")+":6", src.Origin("groups[dev].injectors[api].configs"))
+
+	out, err := src.YAML(true)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "  ttl: 600 # "+filepath.Join(user, "esi.yml")+":2\n")
+	assert.Contains(t, string(out), "        env_mode: clean # "+filepath.Join(project, ".esi.yml")+":6\n")
+	assert.NotContains(t, string(out), "include")
+}
+
+func TestLoadSourceExplicit(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "custom.yml")
+	writeFile(t, path, `include: [shared.yml, missing/*.yml, custom.yml]
+secret_server:
+  url: https://override.example.com
+`)
+	writeFile(t, filepath.Join(dir, "shared.yml"), `secret_server:
+  url: https://shared.example.com
+  ttl: 60
+`)
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, "https://override.example.com", cfg.SecretServer.URL)
+	assert.Equal(t, uint(60), cfg.SecretServer.TTL)
+}
+
+func TestLoadSourceMixedLists(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "esi.yml")
+	writeFile(t, path, `include: [base.yml]
+groups:
+  - name: dev
+    injectors:
+      - name: api
+        configs:
+          - stdout: true
+            stdout_secret: db
+secrets:
+  - id: db
+    secret_id: 1
+`)
+	// a group without name can't be merged by name
+	writeFile(t, filepath.Join(dir, "base.yml"), `groups:
+  - name: dev
+  - selected: true
+`)
+
+	src, err := LoadSource(path)
+	require.NoError(t, err)
+	cfg, err := src.Config()
+	require.NoError(t, err)
+	require.Len(t, cfg.Groups, 1)
+	assert.NotNil(t, cfg.findInjector("dev.api"))
+	assert.Equal(t, path+":3", src.Origin("groups[dev].name"))
+
+	// the other way around, the unkeyed list replaces the keyed one and is validated
+	writeFile(t, path, `include: [base.yml]
+groups:
+  - name: dev
+  - selected: true
+`)
+	writeFile(t, filepath.Join(dir, "base.yml"), `groups:
+  - name: dev
+    injectors:
+      - name: api
+`)
+	_, err = Load(path)
+	var verr *ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.Len(t, verr.Problems, 1)
+	assert.Equal(t, path+":4: group has no name", verr.Problems[0].String())
+}
@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MergeInjectors combines the injectors into a single one, so that a command gets the secrets of all of them.
+// It fails if two injectors set the same env var or tmp file var, or if their env modes differ.
+// A single injector is returned as is.
+func MergeInjectors(injectors ...*Injector) (*Injector, error) {
+	injectors = uniqueInjectors(injectors)
+	switch len(injectors) {
+	case 0:
+		return nil, fmt.Errorf("no injectors to merge")
+	case 1:
+		return injectors[0], nil
+	}
+
+	names := make([]string, 0, len(injectors))
+	for _, inj := range injectors {
+		names = append(names, inj.Name)
+	}
+	merged := &Injector{
+		Name:             strings.Join(names, "+"),
+		AllowArgvSecrets: true,
+	}
+
+	// owners maps env vars to the index of the injector setting them.
+	// Tmp file vars are exported as env vars as well, so they share the owners.
+	owners := make(map[string]int)
+	claim := func(kind, key string, i int) error {
+		if owner, ok := owners[key]; ok && owner != i {
+			return fmt.Errorf("%s %q is set by injector %q and %q", kind, key, injectors[owner].Name, injectors[i].Name)
+		}
+		owners[key] = i
+		return nil
+	}
+
+	for i, inj := range injectors {
+		mode := inj.EnvMode
+		if mode == "" {
+			mode = EnvModeInherit
+		}
+		if i == 0 {
+			merged.EnvMode = mode
+		} else if mode != merged.EnvMode {
+			return nil, fmt.Errorf("env mode %q of injector %q conflicts with env mode %q", mode, inj.Name, merged.EnvMode)
+		}
+
+		for _, e := range inj.Env {
+			key, _, _ := strings.Cut(e, "=")
+			if err := claim("env var", key, i); err != nil {
+				return nil, err
+			}
+		}
+		for _, c := range inj.Configs {
+			if c.EnvKey != "" {
+				if err := claim("env var", c.EnvKey, i); err != nil {
+					return nil, err
+				}
+			}
+			if c.TmpFile && c.TmpFileVar != "" {
+				if err := claim("tmp file var", c.TmpFileVar, i); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		// argv secrets are only allowed, if all injectors allow them
+		merged.AllowArgvSecrets = merged.AllowArgvSecrets && inj.AllowArgvSecrets
+		merged.EnvAllow = append(merged.EnvAllow, inj.EnvAllow...)
+		merged.EnvDeny = append(merged.EnvDeny, inj.EnvDeny...)
+		merged.Env = append(merged.Env, inj.Env...)
+		merged.MaxRuntime = lowerLimit(merged.MaxRuntime, inj.MaxRuntime)
+		merged.Configs = append(merged.Configs, inj.Configs...)
+	}
+	return merged, nil
+}
+
+// uniqueInjectors removes duplicate injectors and keeps the order.
+func uniqueInjectors(injectors []*Injector) []*Injector {
+	seen := make(map[*Injector]bool, len(injectors))
+	unique := make([]*Injector, 0, len(injectors))
+	for _, inj := range injectors {
+		if inj == nil || seen[inj] {
+			continue
+		}
+		seen[inj] = true
+		unique = append(unique, inj)
+	}
+	return unique
+}
+
+// lowerLimit returns the lower of both limits. Zero means unlimited.
+func lowerLimit(a, b time.Duration) time.Duration {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,124 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeInjectors(t *testing.T) {
+	db := &Injector{
+		Name:             "readonly",
+		AllowArgvSecrets: true,
+		Env:              []string{"PGSSLMODE=require"},
+		MaxRuntime:       time.Hour,
+		Configs: []*InjectorConfig{
+			{EnvKey: "PGUSER", EnvSecret: "db-user"},
+			{TmpFile: true, TmpFileVar: "PGPASSFILE"},
+		},
+	}
+	cloud := &Injector{
+		Name:       "deploy",
+		EnvMode:    EnvModeInherit,
+		EnvDeny:    []string{"AWS_*"},
+		MaxRuntime: 30 * time.Minute,
+		Configs: []*InjectorConfig{
+			{EnvKey: "CLOUD_TOKEN", EnvSecret: "cloud-token"},
+		},
+	}
+
+	merged, err := MergeInjectors(db, cloud, db)
+	require.NoError(t, err)
+	assert.Equal(t, "readonly+deploy", merged.Name)
+	assert.Equal(t, EnvModeInherit, merged.EnvMode)
+	assert.False(t, merged.AllowArgvSecrets)
+	assert.Equal(t, []string{"PGSSLMODE=require"}, merged.Env)
+	assert.Equal(t, []string{"AWS_*"}, merged.EnvDeny)
+	assert.Equal(t, 30*time.Minute, merged.MaxRuntime)
+	assert.Equal(t, append(db.Configs, cloud.Configs...), merged.Configs)
+
+	single, err := MergeInjectors(db)
+	require.NoError(t, err)
+	assert.Same(t, db, single)
+}
+
+func TestMergeInjectorsConflicts(t *testing.T) {
+	type testCase struct {
+		name  string
+		other *Injector
+		err   string
+	}
+
+	base := &Injector{
+		Name: "a",
+		Env:  []string{"STATIC=1"},
+		Configs: []*InjectorConfig{
+			{EnvKey: "TOKEN"},
+			{TmpFile: true, TmpFileVar: "CONFIG"},
+		},
+	}
+
+	testCases := []testCase{
+		{
+			name:  "env key",
+			other: &Injector{Name: "b", Configs: []*InjectorConfig{{EnvKey: "TOKEN"}}},
+			err:   `env var "TOKEN" is set by injector "a" and "b"`,
+		},
+		{
+			name:  "static env var",
+			other: &Injector{Name: "b", Configs: []*InjectorConfig{{EnvKey: "STATIC"}}},
+			err:   `env var "STATIC" is set by injector "a" and "b"`,
+		},
+		{
+			name:  "tmp file var",
+			other: &Injector{Name: "b", Configs: []*InjectorConfig{{TmpFile: true, TmpFileVar: "CONFIG"}}},
+			err:   `tmp file var "CONFIG" is set by injector "a" and "b"`,
+		},
+		{
+			name:  "env var and tmp file var",
+			other: &Injector{Name: "b", Configs: []*InjectorConfig{{TmpFile: true, TmpFileVar: "TOKEN"}}},
+			err:   `tmp file var "TOKEN" is set by injector "a" and "b"`,
+		},
+		{
+			name:  "same name",
+			other: &Injector{Name: "a", Configs: []*InjectorConfig{{EnvKey: "TOKEN"}}},
+			err:   `env var "TOKEN" is set by injector "a" and "a"`,
+		},
+		{
+			name:  "env mode",
+			other: &Injector{Name: "b", EnvMode: EnvModeClean},
+			err:   `env mode "clean" of injector "b" conflicts with env mode "inherit"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := MergeInjectors(base, tc.other)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestInjectorClone(t *testing.T) {
+	inj := &Injector{
+		Name: "api",
+		Env:  []string{"APP_ENV=dev"},
+		Configs: []*InjectorConfig{
+			{TmpFile: true, TmpFileSecrets: []string{"db"}, Secrets: map[string]*Secret{"db": {ID: "db"}}},
+		},
+	}
+
+	cp := inj.Clone()
+	assert.Equal(t, inj.Name, cp.Name)
+	assert.Equal(t, inj.Env, cp.Env)
+	require.Len(t, cp.Configs, 1)
+	assert.NotSame(t, inj.Configs[0], cp.Configs[0])
+	assert.Nil(t, cp.Configs[0].Secrets)
+
+	cp.Env[0] = "APP_ENV=prod"
+	cp.Configs[0].TmpFileSecrets[0] = "cert"
+	assert.Equal(t, "APP_ENV=dev", inj.Env[0])
+	assert.Equal(t, "db", inj.Configs[0].TmpFileSecrets[0])
+}
@@ -0,0 +1,306 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jon4hz/esi/tmpl"
+	"gopkg.in/yaml.v3"
+)
+
+// Problem is a mistake in the config.
+type Problem struct {
+	// Origin is the file and line of the mistake, if known.
+	Origin  string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Origin == "" {
+		return p.Message
+	}
+	return p.Origin + ": " + p.Message
+}
+
+// ValidationError is returned, if the config contains mistakes.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, fmt.Sprintf("found %d problem(s) in the config:", len(e.Problems)))
+	for _, p := range e.Problems {
+		lines = append(lines, p.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// sortProblems sorts the problems in the order of the files and lines they were found in.
+// Problems without origin are sorted last.
+func sortProblems(problems []Problem, files []string) {
+	order := make(map[string]int, len(files))
+	for i, f := range files {
+		order[f] = i
+	}
+	position := func(p Problem) (int, int) {
+		i := strings.LastIndex(p.Origin, ":")
+		if i < 0 {
+			return len(files), 0
+		}
+		file, ok := order[p.Origin[:i]]
+		if !ok {
+			file = len(files)
+		}
+		line, _ := strconv.Atoi(p.Origin[i+1:])
+		return file, line
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		fi, li := position(problems[i])
+		fj, lj := position(problems[j])
+		if fi != fj {
+			return fi < fj
+		}
+		return li < lj
+	})
+}
+
+// checkKeys reports unknown keys and duplicate list items in the yaml node, which is decoded into t.
+// Items of the same list in different files are merged, so only duplicates within a file are mistakes.
+func (s *Source) checkKeys(n *yaml.Node, t reflect.Type, file string, root bool) {
+	n = resolveAlias(n)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t.Kind() == reflect.Struct && n.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, v := n.Content[i], resolveAlias(n.Content[i+1])
+			if root && strings.EqualFold(k.Value, includeKey) {
+				continue
+			}
+			f, ok := fieldByKey(t, k.Value)
+			if !ok {
+				s.problems = append(s.problems, Problem{
+					Origin:  fmt.Sprintf("%s:%d", file, k.Line),
+					Message: fmt.Sprintf("unknown key %q", k.Value),
+				})
+				continue
+			}
+			if key := mergeKeys[strings.ToLower(k.Value)]; key != "" && v.Kind == yaml.SequenceNode {
+				s.checkDuplicates(v, key, strings.ToLower(k.Value), file)
+			}
+			s.checkKeys(v, f.Type, file, false)
+		}
+
+	case t.Kind() == reflect.Slice && n.Kind == yaml.SequenceNode:
+		for _, item := range n.Content {
+			s.checkKeys(item, t.Elem(), file, false)
+		}
+	}
+}
+
+// checkDuplicates reports items of the yaml sequence with the same key.
+func (s *Source) checkDuplicates(seq *yaml.Node, key, list, file string) {
+	seen := make(map[string]bool, len(seq.Content))
+	for _, item := range seq.Content {
+		item = resolveAlias(item)
+		if item.Kind != yaml.MappingNode {
+			continue
+		}
+		v := mappingValue(item, key)
+		if v == nil || v.Value == "" {
+			continue
+		}
+		if seen[strings.ToLower(v.Value)] {
+			s.problems = append(s.problems, Problem{
+				Origin:  fmt.Sprintf("%s:%d", file, v.Line),
+				Message: fmt.Sprintf("duplicate %s %q in %s", key, v.Value, list),
+			})
+		}
+		seen[strings.ToLower(v.Value)] = true
+	}
+}
+
+// fieldByKey returns the field of the struct, which is decoded from the key.
+func fieldByKey(t reflect.Type, key string) (reflect.StructField, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if name != "" && name != "-" && strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// validator checks the references and settings of a decoded config.
+type validator struct {
+	src      *Source
+	cfg      *Config
+	problems []Problem
+}
+
+// validate returns the mistakes in the decoded config. It must be called before the config is expanded,
+// so that the configs of the injectors still match the config files.
+func (s *Source) validate(cfg *Config) []Problem {
+	v := &validator{src: s, cfg: cfg}
+
+	ids := make(map[string]bool, len(cfg.Secrets))
+	for i, secret := range cfg.Secrets {
+		path := s.itemPath("secrets", i)
+		switch {
+		case secret.ID == "":
+			v.addf(path, "secret has no id")
+		case ids[strings.ToLower(secret.ID)]:
+			v.addf(path+".id", "duplicate id %q in secrets", secret.ID)
+		}
+		ids[strings.ToLower(secret.ID)] = true
+		if secret.SecretID == 0 {
+			v.addf(path, "secret %q has no secret_id", secret.ID)
+		}
+		for j, t := range secret.Transforms {
+			switch options := t.Options(); len(options) {
+			case 0:
+				v.addf(fmt.Sprintf("%s.transforms[%d]", path, j), "transform sets no option")
+			case 1:
+			default:
+				v.addf(fmt.Sprintf("%s.transforms[%d]", path, j), "transform sets multiple options (%s), use a separate transform for each", strings.Join(options, ", "))
+			}
+		}
+	}
+
+	snippets := make(map[string]bool, len(cfg.Snippets))
+	for i, snippet := range cfg.Snippets {
+		path := s.itemPath("snippets", i)
+		snippets[strings.ToLower(snippet.Name)] = true
+		for j, ic := range snippet.Configs {
+			v.config(fmt.Sprintf("%s.configs[%d]", path, j), ic)
+		}
+	}
+
+	groups := make(map[string]bool, len(cfg.Groups))
+	for i, g := range cfg.Groups {
+		path := s.itemPath("groups", i)
+		switch {
+		case g.Name == "":
+			v.addf(path, "group has no name")
+		case groups[strings.ToLower(g.Name)]:
+			v.addf(path+".name", "duplicate name %q in groups", g.Name)
+		}
+		groups[strings.ToLower(g.Name)] = true
+
+		injectors := make(map[string]bool, len(g.Injectors))
+		for j, inj := range g.Injectors {
+			injPath := s.itemPath(path+".injectors", j)
+			switch {
+			case inj.Name == "":
+				v.addf(injPath, "injector has no name")
+			case injectors[strings.ToLower(inj.Name)]:
+				v.addf(injPath+".name", "duplicate name %q in injectors", inj.Name)
+			}
+			injectors[strings.ToLower(inj.Name)] = true
+			v.injector(injPath, inj, snippets)
+		}
+	}
+
+	for i, p := range cfg.Processes {
+		path := s.itemPath("processes", i)
+		if p.Injector != "" && cfg.findInjector(p.Injector) == nil {
+			v.addf(path+".injector", "unknown injector %q", p.Injector)
+		}
+		for j, fqdn := range p.Injectors {
+			if cfg.findInjector(fqdn) == nil {
+				v.addf(fmt.Sprintf("%s.injectors[%d]", path, j), "unknown injector %q", fqdn)
+			}
+		}
+	}
+	return v.problems
+}
+
+func (v *validator) addf(path, format string, args ...any) {
+	v.problems = append(v.problems, Problem{Origin: v.src.Origin(path), Message: fmt.Sprintf(format, args...)})
+}
+
+// injector checks the settings of the injector at path and its configs.
+func (v *validator) injector(path string, inj *Injector, snippets map[string]bool) {
+	switch inj.EnvMode {
+	case "", EnvModeInherit, EnvModeClean, EnvModeAllowlist:
+	default:
+		v.addf(path+".env_mode", "invalid env_mode %q (use %s, %s or %s)", inj.EnvMode, EnvModeInherit, EnvModeClean, EnvModeAllowlist)
+	}
+	for i, e := range inj.Env {
+		if k, _, ok := strings.Cut(e, "="); !ok || k == "" {
+			v.addf(fmt.Sprintf("%s.env[%d]", path, i), "static env var %q must have the form KEY=value", e)
+		}
+	}
+	if inj.Extends != "" && v.cfg.findInjector(inj.Extends) == nil {
+		v.addf(path+".extends", "unknown injector %q", inj.Extends)
+	}
+	for i, ref := range inj.Use {
+		if !snippets[strings.ToLower(ref.Snippet)] {
+			v.addf(fmt.Sprintf("%s.use[%d].snippet", path, i), "unknown snippet %q", ref.Snippet)
+		}
+	}
+	for i, ic := range inj.Configs {
+		v.config(fmt.Sprintf("%s.configs[%d]", path, i), ic)
+	}
+}
+
+// config checks the injector config at path. IDs containing snippet params are only known, once the snippet is used.
+func (v *validator) config(path string, ic *InjectorConfig) {
+	if ic.EnvKey == "" && !ic.Stdout && !ic.TmpFile {
+		v.addf(path, "config sets no injector type (env_key, stdout or tmp_file)")
+	}
+	if ic.EnvKey != "" && ic.EnvSecret == "" {
+		v.addf(path+".env_key", "env_key %q has no env_secret", ic.EnvKey)
+	}
+	if ic.Stdout && ic.StdoutSecret == "" {
+		v.addf(path+".stdout", "stdout has no stdout_secret")
+	}
+	v.secretID(path+".env_secret", ic.EnvSecret)
+	v.secretID(path+".stdout_secret", ic.StdoutSecret)
+	for i, id := range ic.TmpFileSecrets {
+		v.secretID(fmt.Sprintf("%s.tmp_file_secrets[%d]", path, i), id)
+	}
+	if !ic.TmpFile {
+		return
+	}
+
+	tmplPath := path + ".tmp_file_tmpl"
+	if ic.TmpFileTmplPath != "" {
+		tmplPath = path + ".tmp_file_tmpl_path"
+		if paramPattern.MatchString(ic.TmpFileTmplPath) {
+			return
+		}
+	}
+	text, err := ic.Template()
+	if err != nil {
+		v.addf(tmplPath, "%v", err)
+		return
+	}
+	ids, err := tmpl.SecretIDs(text)
+	if err != nil {
+		v.addf(tmplPath, "invalid template: %v", err)
+		return
+	}
+	for _, id := range ids {
+		v.secretID(tmplPath, id)
+	}
+}
+
+// secretID reports the id, if no secret has it.
+func (v *validator) secretID(path, id string) {
+	if id == "" || paramPattern.MatchString(id) {
+		return
+	}
+	for _, s := range v.cfg.Secrets {
+		if strings.EqualFold(s.ID, id) {
+			return
+		}
+	}
+	v.addf(path, "unknown secret id %q", id)
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	type testCase struct {
+		name     string
+		cfg      string
+		problems []string
+	}
+
+	testCases := []testCase{
+		{
+			name: "valid",
+			cfg: `secrets:
+  - id: db
+    secret_id: 1
+snippets:
+  - name: token
+    params: [id]
+    configs:
+      - env_key: TOKEN
+        env_secret: ${id}
+groups:
+  - name: dev
+    injectors:
+      - name: api
+        env_mode: clean
+        env: [APP_ENV=dev]
+        use:
+          - snippet: token
+            with: {id: db}
+        configs:
+          - tmp_file: true
+            tmp_file_tmpl: '{{ secret "db" }}'
+processes:
+  - name: api
+    command: ./api
+    injector: dev.api
+`,
+		},
+		{
+			name: "unknown keys",
+			cfg: `secret_server:
+  urll: https://tss.example.com
+groups:
+  - name: dev
+    injectors:
+      - name: api
+        configs:
+          - env_key: TOKEN
+            env_secrett: db
+`,
+			problems: []string{
+				`2: unknown key "urll"`,
+				`8: env_key "TOKEN" has no env_secret`,
+				`9: unknown key "env_secrett"`,
+			},
+		},
+		{
+			name: "duplicates",
+			cfg: `secrets:
+  - id: db
+    secret_id: 1
+  - id: DB
+    secret_id: 2
+groups:
+  - name: dev
+    injectors:
+      - name: api
+        configs: [{stdout: true, stdout_secret: db}]
+      - name: api
+        configs: [{stdout: true, stdout_secret: db}]
+  - name: dev
+`,
+			problems: []string{
+				`4: duplicate id "DB" in secrets`,
+				`11: duplicate name "api" in injectors`,
+				`13: duplicate name "dev" in groups`,
+			},
+		},
+		{
+			name: "unknown references",
+			cfg: `secrets:
+  - id: db
+    secret_id: 1
+groups:
+  - name: dev
+    injectors:
+      - name: api
+        extends: dev.base
+        use:
+          - snippet: cache
+        configs:
+          - env_key: TOKEN
+            env_secret: token
+          - tmp_file: true
+            tmp_file_secrets: [db, cert]
+processes:
+  - name: api
+    command: ./api
+    injectors: [dev.api, dev.worker]
+`,
+			problems: []string{
+				`8: unknown injector "dev.base"`,
+				`10: unknown snippet "cache"`,
+				`13: unknown secret id "token"`,
+				`15: unknown secret id "cert"`,
+				`19: unknown injector "dev.worker"`,
+			},
+		},
+		{
+			name: "invalid settings",
+			cfg: `secrets:
+  - id: db
+  - id: cert
+    secret_id: 2
+    transforms:
+      - jsonpath: .cert
+      - base64decode: true
+        trim: true
+      - {}
+groups:
+  - name: dev
+    injectors:
+      - name: api
+        env_mode: none
+        env: [APP_ENV]
+        configs:
+          - env_secret: db
+          - tmp_file: true
+            tmp_file_tmpl: '{{ secret "db" }'
+          - tmp_file: true
+            tmp_file_tmpl: '{{ secret "dbx" }}'
+`,
+			problems: []string{
+				`2: secret "db" has no secret_id`,
+				`7: transform sets multiple options (base64decode, trim), use a separate transform for each`,
+				`9: transform sets no option`,
+				`14: invalid env_mode "none" (use inherit, clean or allowlist)`,
+				`15: static env var "APP_ENV" must have the form KEY=value`,
+				`17: config sets no injector type (env_key, stdout or tmp_file)`,
+				`19: invalid template: template: :1: unexpected "}" in operand`,
+				`21: unknown secret id "dbx"`,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "esi.yml")
+			writeFile(t, path, tc.cfg)
+
+			_, err := Load(path)
+			if len(tc.problems) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			var verr *ValidationError
+			require.True(t, errors.As(err, &verr), "unexpected error: %v", err)
+			problems := make([]string, 0, len(verr.Problems))
+			for _, p := range verr.Problems {
+				problems = append(problems, p.String())
+			}
+			expected := make([]string, 0, len(tc.problems))
+			for _, p := range tc.problems {
+				expected = append(expected, fmt.Sprintf("%s:%s", path, p))
+			}
+			assert.Equal(t, expected, problems)
+		})
+	}
+}
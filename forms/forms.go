@@ -1,8 +1,9 @@
 package forms
 
 import (
+	"os"
+
 	"github.com/charmbracelet/huh"
-	"github.com/jon4hz/esi/config"
 )
 
 func TokenInputForm(token *string) *huh.Form {
@@ -29,32 +30,18 @@ func PasswordInputForm(password *string) *huh.Form {
 	)
 }
 
-func GroupSelectForm(groups []*config.Group, out **config.Group) *huh.Form {
-	options := make([]huh.Option[*config.Group], 0, len(groups))
-	for _, g := range groups {
-		options = append(options, huh.NewOption(g.Name, g).Selected(g.Selected))
-	}
-	return huh.NewForm(
-		huh.NewGroup(
-			huh.NewSelect[*config.Group]().
-				Options(options...).
-				Title("Select Group").
-				Value(out),
-		),
-	)
+// Token asks for the TSS API token.
+func Token(token *string) error {
+	return run(TokenInputForm(token).Run, func(tty *os.File) (err error) {
+		*token, err = readSecret(tty, "TSS API Token")
+		return err
+	})
 }
 
-func InjectorSelectForm(injectors []*config.Injector, out **config.Injector) *huh.Form {
-	options := make([]huh.Option[*config.Injector], 0, len(injectors))
-	for _, inj := range injectors {
-		options = append(options, huh.NewOption(inj.Name, inj).Selected(inj.Selected))
-	}
-	return huh.NewForm(
-		huh.NewGroup(
-			huh.NewSelect[*config.Injector]().
-				Options(options...).
-				Title("Select Injectors").
-				Value(out),
-		),
-	)
+// Password asks for the local encryption password.
+func Password(password *string) error {
+	return run(PasswordInputForm(password).Run, func(tty *os.File) (err error) {
+		*password, err = readSecret(tty, "Local encryption password")
+		return err
+	})
 }
@@ -0,0 +1,62 @@
+package forms
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// fuzzyScore reports whether all characters of pattern appear in s in the same order, ignoring case.
+// Consecutive matches and matches at the start of a word (e.g. after a dot) score higher.
+func fuzzyScore(pattern, s string) (int, bool) {
+	pattern, s = strings.ToLower(pattern), strings.ToLower(s)
+	var score, streak int
+	prev := '.'
+	p := 0
+	for _, c := range s {
+		if p == len(pattern) {
+			break
+		}
+		want, size := utf8.DecodeRuneInString(pattern[p:])
+		if c != want {
+			streak = 0
+			prev = c
+			continue
+		}
+		p += size
+		streak++
+		score += streak
+		if prev == '.' || prev == '-' || prev == '_' || prev == ' ' {
+			score += 3
+		}
+		prev = c
+	}
+	if p < len(pattern) {
+		return 0, false
+	}
+	// prefer shorter candidates if the matches are equally good
+	return score*100 - len(s), true
+}
+
+// fuzzyFilter returns the indexes of the candidates matching the pattern, best matches first.
+// An empty pattern matches all candidates in their original order.
+func fuzzyFilter(pattern string, candidates []string) []int {
+	type match struct {
+		index int
+		score int
+	}
+	matches := make([]match, 0, len(candidates))
+	for i, c := range candidates {
+		if score, ok := fuzzyScore(pattern, c); ok {
+			matches = append(matches, match{index: i, score: score})
+		}
+	}
+	if pattern != "" {
+		sort.SliceStable(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	}
+	indexes := make([]int, 0, len(matches))
+	for _, m := range matches {
+		indexes = append(indexes, m.index)
+	}
+	return indexes
+}
@@ -0,0 +1,32 @@
+package forms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFuzzyFilter(t *testing.T) {
+	type testCase struct {
+		pattern  string
+		expected []int
+	}
+
+	candidates := []string{"dev.api", "dev.worker", "prod.api", "prod.database"}
+
+	testCases := []testCase{
+		{pattern: "", expected: []int{0, 1, 2, 3}},
+		{pattern: "api", expected: []int{0, 2}},
+		{pattern: "PAPI", expected: []int{2}},
+		{pattern: "pdb", expected: []int{3}},
+		{pattern: "a", expected: []int{0, 2, 3}},
+		{pattern: "dw", expected: []int{1}},
+		{pattern: "xyz", expected: []int{}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern, func(t *testing.T) {
+			assert.Equal(t, tc.expected, fuzzyFilter(tc.pattern, candidates))
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package forms
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/huh"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jon4hz/esi/config"
+	"github.com/jon4hz/esi/tmpl"
+)
+
+// pickerHeight is the number of injectors shown at once.
+const pickerHeight = 10
+
+var (
+	pickerTitleStyle   = lipgloss.NewStyle().Bold(true)
+	pickerCursorStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("212"))
+	pickerDimStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	pickerPreviewStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("240")).Padding(0, 1)
+)
+
+// Choice is an injector together with the group it belongs to.
+type Choice struct {
+	Group    *config.Group
+	Injector *config.Injector
+}
+
+// FQDN returns the fully qualified name of the injector.
+func (c Choice) FQDN() string {
+	return c.Group.Name + "." + c.Injector.Name
+}
+
+// PickInjectors lets the user pick one or more injectors of any group from a single list,
+// which is filtered by typing. A preview shows what the highlighted injector injects.
+// The injectors with the fqdns last are preselected, otherwise the one marked as selected in the config.
+func PickInjectors(groups []*config.Group, last []string, out *[]Choice) error {
+	var choices []Choice
+	for _, g := range groups {
+		for _, inj := range g.Injectors {
+			choices = append(choices, Choice{Group: g, Injector: inj})
+		}
+	}
+	if len(choices) == 0 {
+		return errors.New("no injectors configured")
+	}
+	defs := defaultChoices(choices, last)
+
+	return run(func() error {
+		m, err := tea.NewProgram(newPicker(choices, defs)).Run()
+		if err != nil {
+			return err
+		}
+		picked := m.(picker).picked
+		if len(picked) == 0 {
+			return huh.ErrUserAborted
+		}
+		*out = picked
+		return nil
+	}, func(tty *os.File) error {
+		fqdns := make([]string, 0, len(choices))
+		for _, c := range choices {
+			fqdns = append(fqdns, c.FQDN())
+		}
+		indexes, err := selectNumbers(tty, tty, "Select Injectors", fqdns, defs)
+		if err != nil {
+			return err
+		}
+		picked := make([]Choice, 0, len(indexes))
+		for _, i := range indexes {
+			picked = append(picked, choices[i])
+		}
+		*out = picked
+		return nil
+	})
+}
+
+// defaultChoices returns the indexes of the injectors, which are preselected.
+func defaultChoices(choices []Choice, last []string) []int {
+	var defs []int
+	for _, fqdn := range last {
+		for i, c := range choices {
+			if strings.EqualFold(c.FQDN(), fqdn) {
+				defs = append(defs, i)
+				break
+			}
+		}
+	}
+	if len(defs) > 0 {
+		return defs
+	}
+	for i, c := range choices {
+		if c.Group.Selected && c.Injector.Selected {
+			return []int{i}
+		}
+	}
+	for i, c := range choices {
+		if c.Injector.Selected {
+			return []int{i}
+		}
+	}
+	return nil
+}
+
+// preview describes what the injector injects. It lists the keys of the env vars,
+// the vars of the tmp files and the IDs of the secrets, but never any values.
+func preview(inj *config.Injector) string {
+	var envKeys, tmpFileVars, secretIDs []string
+	seen := make(map[string]bool)
+	addSecret := func(id string) {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			secretIDs = append(secretIDs, id)
+		}
+	}
+
+	for _, e := range inj.Env {
+		key, _, _ := strings.Cut(e, "=")
+		envKeys = append(envKeys, key)
+	}
+	var stdout bool
+	for _, c := range inj.Configs {
+		if c.EnvKey != "" {
+			envKeys = append(envKeys, c.EnvKey)
+		}
+		addSecret(c.EnvSecret)
+		if c.Stdout {
+			stdout = true
+			addSecret(c.StdoutSecret)
+		}
+		if c.TmpFile {
+			if c.TmpFileVar != "" {
+				tmpFileVars = append(tmpFileVars, c.TmpFileVar)
+			}
+			for _, id := range c.TmpFileSecrets {
+				addSecret(id)
+			}
+			if text, err := c.Template(); err == nil {
+				ids, _ := tmpl.SecretIDs(text)
+				for _, id := range ids {
+					addSecret(id)
+				}
+			}
+		}
+	}
+
+	list := func(items []string) string {
+		if len(items) == 0 {
+			return pickerDimStyle.Render("none")
+		}
+		return strings.Join(items, ", ")
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s\n", pickerTitleStyle.Render("Env vars:"), list(envKeys))
+	fmt.Fprintf(&b, "%s %s\n", pickerTitleStyle.Render("Tmp file vars:"), list(tmpFileVars))
+	if stdout {
+		fmt.Fprintf(&b, "%s yes\n", pickerTitleStyle.Render("Stdout:"))
+	}
+	fmt.Fprintf(&b, "%s %s", pickerTitleStyle.Render("Secrets:"), list(secretIDs))
+	return b.String()
+}
+
+// picker is a bubbletea model of a filterable list of injectors.
+// If no injector is toggled, the highlighted one is picked.
+type picker struct {
+	choices []Choice
+	fqdns   []string
+	filter  textinput.Model
+	// matches are the indexes of the choices matching the filter
+	matches []int
+	// cursor is the index of the highlighted match
+	cursor int
+	// offset is the index of the first visible match
+	offset int
+	// toggled contains the indexes of the choices selected with tab
+	toggled map[int]bool
+	picked  []Choice
+	done    bool
+}
+
+// newPicker highlights the first default choice. Multiple default choices are toggled.
+func newPicker(choices []Choice, defs []int) picker {
+	filter := textinput.New()
+	filter.Prompt = "/ "
+	filter.Placeholder = "type to filter"
+	filter.Focus()
+
+	p := picker{choices: choices, filter: filter, toggled: make(map[int]bool)}
+	for _, c := range choices {
+		p.fqdns = append(p.fqdns, c.FQDN())
+	}
+	p.matches = fuzzyFilter("", p.fqdns)
+	if len(defs) > 0 {
+		p.cursor = defs[0]
+	}
+	if len(defs) > 1 {
+		for _, d := range defs {
+			p.toggled[d] = true
+		}
+	}
+	p.scroll()
+	return p
+}
+
+func (p picker) Init() tea.Cmd {
+	return textinput.Blink
+}
+
+func (p picker) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "ctrl+c", "esc":
+			p.done = true
+			return p, tea.Quit
+		case "enter":
+			for i, c := range p.choices {
+				if p.toggled[i] {
+					p.picked = append(p.picked, c)
+				}
+			}
+			if len(p.picked) == 0 {
+				if len(p.matches) == 0 {
+					return p, nil
+				}
+				p.picked = []Choice{p.choices[p.matches[p.cursor]]}
+			}
+			p.done = true
+			return p, tea.Quit
+		case "tab":
+			if len(p.matches) > 0 {
+				i := p.matches[p.cursor]
+				p.toggled[i] = !p.toggled[i]
+			}
+			return p, nil
+		case "up", "ctrl+p":
+			if p.cursor > 0 {
+				p.cursor--
+			}
+			p.scroll()
+			return p, nil
+		case "down", "ctrl+n":
+			if p.cursor < len(p.matches)-1 {
+				p.cursor++
+			}
+			p.scroll()
+			return p, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	value := p.filter.Value()
+	p.filter, cmd = p.filter.Update(msg)
+	if p.filter.Value() != value {
+		p.matches = fuzzyFilter(p.filter.Value(), p.fqdns)
+		p.cursor, p.offset = 0, 0
+	}
+	return p, cmd
+}
+
+// scroll makes sure the cursor is visible.
+func (p *picker) scroll() {
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+pickerHeight {
+		p.offset = p.cursor - pickerHeight + 1
+	}
+}
+
+func (p picker) View() string {
+	if p.done {
+		return ""
+	}
+
+	var list strings.Builder
+	fmt.Fprintln(&list, pickerTitleStyle.Render("Select Injectors"))
+	fmt.Fprintln(&list, p.filter.View())
+	if len(p.matches) == 0 {
+		fmt.Fprint(&list, pickerDimStyle.Render("no matching injector"))
+	}
+	for i := p.offset; i < len(p.matches) && i < p.offset+pickerHeight; i++ {
+		row := "  " + p.fqdns[p.matches[i]]
+		if p.toggled[p.matches[i]] {
+			row = "✓ " + p.fqdns[p.matches[i]]
+		}
+		if i == p.cursor {
+			fmt.Fprintln(&list, pickerCursorStyle.Render("> "+row))
+		} else {
+			fmt.Fprintln(&list, "  "+row)
+		}
+	}
+
+	view := list.String()
+	if len(p.matches) > 0 {
+		view = lipgloss.JoinHorizontal(lipgloss.Top, view, "  ", pickerPreviewStyle.Render(preview(p.choices[p.matches[p.cursor]].Injector)))
+	}
+	return view + "\n" + pickerDimStyle.Render("↑/↓ navigate • tab toggle • enter select • esc quit") + "\n"
+}
@@ -0,0 +1,45 @@
+package forms
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jon4hz/esi/config"
+	"github.com/muesli/termenv"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreview(t *testing.T) {
+	lipgloss.SetColorProfile(termenv.Ascii)
+
+	inj := &config.Injector{
+		Env: []string{"STATIC=not-a-secret"},
+		Configs: []*config.InjectorConfig{
+			{EnvKey: "DB_USER", EnvSecret: "db-user"},
+			{EnvKey: "DB_PASSWORD", EnvSecret: "db-password"},
+			{TmpFile: true, TmpFileVar: "KUBECONFIG", TmpFileTmpl: `{{ secret "kubeconfig" }}{{ secret "db-user" }}`},
+		},
+	}
+
+	got := preview(inj)
+	assert.Equal(t, "Env vars: STATIC, DB_USER, DB_PASSWORD\nTmp file vars: KUBECONFIG\nSecrets: db-user, db-password, kubeconfig", got)
+	assert.NotContains(t, got, "not-a-secret")
+}
+
+func TestDefaultChoices(t *testing.T) {
+	dev := &config.Group{Name: "dev", Injectors: []*config.Injector{{Name: "api"}, {Name: "worker", Selected: true}}}
+	prod := &config.Group{Name: "prod", Selected: true, Injectors: []*config.Injector{{Name: "api"}, {Name: "worker", Selected: true}}}
+
+	var choices []Choice
+	for _, g := range []*config.Group{dev, prod} {
+		for _, inj := range g.Injectors {
+			choices = append(choices, Choice{Group: g, Injector: inj})
+		}
+	}
+
+	assert.Equal(t, []int{2}, defaultChoices(choices, []string{"PROD.api"}))
+	assert.Equal(t, []int{2, 0}, defaultChoices(choices, []string{"prod.api", "unknown.api", "dev.api"}))
+	assert.Equal(t, []int{3}, defaultChoices(choices, []string{"unknown.api"}))
+	assert.Equal(t, []int{1}, defaultChoices(choices[:2], nil))
+	assert.Empty(t, defaultChoices(choices[:1], nil))
+}
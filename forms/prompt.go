@@ -0,0 +1,105 @@
+package forms
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// ErrNoTTY is returned, if esi has to prompt the user, but there is no terminal to do so.
+var ErrNoTTY = errors.New("no terminal available to prompt")
+
+// ttyPath is the controlling terminal of esi, even if stdin and stdout are redirected.
+const ttyPath = "/dev/tty"
+
+// run runs the interactive prompt, if stdin and stdout are terminals.
+// Otherwise, e.g. in git hooks or if the output of esi is redirected, the prompt would be rendered
+// to the wrong place. In that case, the plain fallback prompts on the controlling terminal instead.
+func run(interactive func() error, fallback func(tty *os.File) error) error {
+	if term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd())) {
+		return interactive()
+	}
+	tty, err := os.OpenFile(ttyPath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrNoTTY, err)
+	}
+	defer tty.Close() // nolint:errcheck
+	return fallback(tty)
+}
+
+// readSecret prints the title and reads a line from the terminal without echoing it.
+func readSecret(tty *os.File, title string) (string, error) {
+	fmt.Fprintf(tty, "%s: ", title)
+	b, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(tty)
+	if err != nil {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+	return string(b), nil
+}
+
+// selectNumbers prints the options as a numbered list and reads the numbers of the selected options,
+// separated by commas or spaces. An empty input selects the default options, unless there are none.
+// The user is asked again, until only valid numbers are entered.
+func selectNumbers(in io.Reader, out io.Writer, title string, options []string, defs []int) ([]int, error) {
+	if len(options) == 0 {
+		return nil, errors.New("nothing to select")
+	}
+	fmt.Fprintln(out, title)
+	for i, o := range options {
+		fmt.Fprintf(out, "  %d) %s\n", i+1, o)
+	}
+
+	defNumbers := make([]string, 0, len(defs))
+	for _, d := range defs {
+		defNumbers = append(defNumbers, strconv.Itoa(d+1))
+	}
+
+	r := bufio.NewReader(in)
+	for {
+		if len(defs) > 0 {
+			fmt.Fprintf(out, "Enter one or more numbers [%s]: ", strings.Join(defNumbers, ","))
+		} else {
+			fmt.Fprint(out, "Enter one or more numbers: ")
+		}
+		line, err := r.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, fmt.Errorf("failed to read input: %w", err)
+		}
+		if line == "" && len(defs) > 0 {
+			return defs, nil
+		}
+		if selected, ok := parseNumbers(line, len(options)); ok {
+			return selected, nil
+		}
+		fmt.Fprintf(out, "Invalid selection %q\n", line)
+	}
+}
+
+// parseNumbers parses a list of numbers between 1 and n and returns them as unique indexes.
+func parseNumbers(s string, n int) ([]int, bool) {
+	fields := strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) == 0 {
+		return nil, false
+	}
+	seen := make(map[int]bool, len(fields))
+	indexes := make([]int, 0, len(fields))
+	for _, f := range fields {
+		i, err := strconv.Atoi(f)
+		if err != nil || i < 1 || i > n {
+			return nil, false
+		}
+		if !seen[i-1] {
+			seen[i-1] = true
+			indexes = append(indexes, i-1)
+		}
+	}
+	return indexes, true
+}
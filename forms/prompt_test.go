@@ -0,0 +1,43 @@
+package forms
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectNumbers(t *testing.T) {
+	type testCase struct {
+		name     string
+		input    string
+		defs     []int
+		expected []int
+		err      bool
+	}
+
+	testCases := []testCase{
+		{name: "number", input: "2\n", expected: []int{1}},
+		{name: "multiple", input: "3, 1 3\n", expected: []int{2, 0}},
+		{name: "default", input: "\n", defs: []int{2, 0}, expected: []int{2, 0}},
+		{name: "without newline", input: "3", expected: []int{2}},
+		{name: "retry", input: "0\nfoo\n\n1,4\n1\n", expected: []int{0}},
+		{name: "eof", input: "", err: true},
+		{name: "invalid and eof", input: "4\n", defs: []int{0}, err: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := selectNumbers(strings.NewReader(tc.input), &out, "Select", []string{"a", "b", "c"}, tc.defs)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, got)
+			assert.Contains(t, out.String(), "  2) b\n")
+		})
+	}
+}
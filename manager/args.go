@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/tmpl"
+)
+
+// argvSecretIDs returns the IDs of all secrets referenced in the given arguments.
+func argvSecretIDs(args []string) []string {
+	var ids []string
+	for _, arg := range args {
+		if !strings.Contains(arg, "{{") {
+			continue
+		}
+		// arguments that aren't valid templates are passed verbatim
+		used, _ := tmpl.SecretIDs(arg)
+		ids = append(ids, used...)
+	}
+	return ids
+}
+
+// renderArgs replaces all secret placeholders like {{ secret "my-id" }} in the arguments.
+// Arguments without any placeholders are passed verbatim, so that arguments like
+// `docker inspect -f '{{ .Name }}'` don't break.
+func (m *Manager) renderArgs(args []string) ([]string, error) {
+	if len(argvSecretIDs(args)) == 0 {
+		return args, nil
+	}
+	if !m.injector.AllowArgvSecrets {
+		log.Warn("Found secret placeholders in the command arguments, but the injector doesn't allow argv secrets. Passing arguments as is.", "injector", m.injector.Name)
+		return args, nil
+	}
+
+	log.Warn("Passing secrets as command arguments! They are visible to all users in /proc and the process list.")
+
+	rendered := make([]string, 0, len(args))
+	for i, arg := range args {
+		if len(argvSecretIDs([]string{arg})) == 0 {
+			rendered = append(rendered, arg)
+			continue
+		}
+		tpl, err := tmpl.New(fmt.Sprintf("arg%d", i), m.secretValue).Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse argument: %w", err)
+		}
+		var b strings.Builder
+		if err := tpl.Execute(&b, nil); err != nil {
+			return nil, fmt.Errorf("failed to render argument: %w", err)
+		}
+		rendered = append(rendered, b.String())
+	}
+	return rendered, nil
+}
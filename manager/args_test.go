@@ -0,0 +1,41 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/jon4hz/esi/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArgvSecretIDs(t *testing.T) {
+	ids := argvSecretIDs([]string{
+		"mysql",
+		`--password={{ secret "db-password" }}`,
+		`--user={{ secret "db-user" }}`,
+		`{{ .Name }}`,
+	})
+	assert.Equal(t, []string{"db-password", "db-user"}, ids)
+}
+
+func TestRenderArgs(t *testing.T) {
+	args := []string{"mysql", `--password={{ secret "db-password" }}`, `{{ .Name }}`}
+
+	secret := &config.Secret{ID: "db-password"}
+	m := Manager{
+		injector: &config.Injector{Name: "db", AllowArgvSecrets: true},
+		secrets:  []*config.Secret{secret},
+	}
+	m.setValue(secret, "s3cret")
+	rendered, err := m.renderArgs(args)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"mysql", "--password=s3cret", `{{ .Name }}`}, rendered)
+
+	m.injector.AllowArgvSecrets = false
+	rendered, err = m.renderArgs(args)
+	assert.NoError(t, err)
+	assert.Equal(t, args, rendered)
+
+	m.injector.AllowArgvSecrets = true
+	_, err = m.renderArgs([]string{`{{ secret "unknown" }}`})
+	assert.Error(t, err)
+}
@@ -0,0 +1,13 @@
+//go:build !windows
+
+package manager
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts the command in a new session, so it doesn't receive any signals from the terminal.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
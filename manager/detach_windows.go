@@ -0,0 +1,13 @@
+//go:build windows
+
+package manager
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach starts the command in a new process group, so it doesn't receive any signals from the console.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+}
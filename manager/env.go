@@ -0,0 +1,130 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/config"
+)
+
+// baseEnv returns the env vars the command inherits from esi according to the env mode of the injector.
+// Env vars matching one of the env_deny patterns are never inherited.
+func baseEnv(inj *config.Injector, environ []string) ([]string, error) {
+	var allowed func(key string) bool
+	switch inj.EnvMode {
+	case "", config.EnvModeInherit:
+		allowed = func(string) bool { return true }
+	case config.EnvModeClean:
+		allowed = func(string) bool { return false }
+	case config.EnvModeAllowlist:
+		allowed = func(key string) bool { return matchAny(inj.EnvAllow, key) }
+	default:
+		return nil, fmt.Errorf("invalid env mode %q", inj.EnvMode)
+	}
+
+	// make sure all patterns are valid, even if they never get to match
+	for _, pattern := range append(inj.EnvAllow, inj.EnvDeny...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid env pattern %q: %w", pattern, err)
+		}
+	}
+
+	env := make([]string, 0, len(environ))
+	for _, e := range environ {
+		key, _, _ := strings.Cut(e, "=")
+		if !allowed(key) || matchAny(inj.EnvDeny, key) {
+			continue
+		}
+		env = append(env, e)
+	}
+	return env, nil
+}
+
+// matchAny reports whether the key matches any of the glob patterns.
+func matchAny(patterns []string, key string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// staticEnvVars returns the static env vars of the injector.
+func staticEnvVars(inj *config.Injector) ([]EnvVar, error) {
+	vars := make([]EnvVar, 0, len(inj.Env))
+	for _, e := range inj.Env {
+		key, value, ok := strings.Cut(e, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid env var %q: expected KEY=value", e)
+		}
+		vars = append(vars, EnvVar{Key: key, Value: value})
+	}
+	return vars, nil
+}
+
+// mergeEnvVars merges the lists of env vars. If a key exists multiple times, the last value wins.
+func mergeEnvVars(lists ...[]EnvVar) []EnvVar {
+	var merged []EnvVar
+	index := make(map[string]int)
+	for _, vars := range lists {
+		for _, e := range vars {
+			if i, ok := index[e.Key]; ok {
+				merged[i] = e
+				continue
+			}
+			index[e.Key] = len(merged)
+			merged = append(merged, e)
+		}
+	}
+	return merged
+}
+
+// buildEnv builds the env of the command from scratch.
+// It sets the base env, the paths of the tmp files and the env vars of the injector.
+func (m *Manager) buildEnv() error {
+	if err := m.setBaseEnv(os.Environ()); err != nil {
+		return err
+	}
+	for _, tf := range m.tmpFiles {
+		if tf.cfg.TmpFileVar != "" {
+			m.addEnv(tf.cfg.TmpFileVar, tf.Path())
+		}
+	}
+	m.setEnvVars(m.injector.Configs)
+	return nil
+}
+
+// setBaseEnv sets the env vars the command starts with: the inherited ones and the static env vars of the injector.
+func (m *Manager) setBaseEnv(environ []string) error {
+	env, err := baseEnv(m.injector, environ)
+	if err != nil {
+		return err
+	}
+	m.env = env
+
+	vars, err := staticEnvVars(m.injector)
+	if err != nil {
+		return err
+	}
+	for _, e := range vars {
+		m.addEnv(e.Key, e.Value)
+	}
+	return nil
+}
+
+// addEnv sets the env var of the command. An existing env var with the same key is replaced.
+func (m *Manager) addEnv(key, value string) {
+	prefix := key + "="
+	for i, e := range m.env {
+		if strings.HasPrefix(e, prefix) {
+			log.Debug("Overriding env var", "key", key)
+			m.env[i] = prefix + value
+			return
+		}
+	}
+	m.env = append(m.env, prefix+value)
+}
@@ -0,0 +1,78 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/jon4hz/esi/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseEnv(t *testing.T) {
+	type testCase struct {
+		name     string
+		injector *config.Injector
+		expected []string
+	}
+
+	environ := []string{"PATH=/usr/bin", "HOME=/home/esi", "AWS_ACCESS_KEY_ID=old", "AWS_PROFILE=dev", "TERM=xterm"}
+
+	testCases := []testCase{
+		{
+			name:     "default",
+			injector: &config.Injector{},
+			expected: environ,
+		},
+		{
+			name:     "inherit with deny",
+			injector: &config.Injector{EnvMode: config.EnvModeInherit, EnvDeny: []string{"AWS_*"}},
+			expected: []string{"PATH=/usr/bin", "HOME=/home/esi", "TERM=xterm"},
+		},
+		{
+			name:     "clean",
+			injector: &config.Injector{EnvMode: config.EnvModeClean, EnvAllow: []string{"PATH"}},
+			expected: []string{},
+		},
+		{
+			name:     "allowlist",
+			injector: &config.Injector{EnvMode: config.EnvModeAllowlist, EnvAllow: []string{"PATH", "AWS_*"}, EnvDeny: []string{"AWS_ACCESS_KEY_ID"}},
+			expected: []string{"PATH=/usr/bin", "AWS_PROFILE=dev"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, err := baseEnv(tc.injector, environ)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestBaseEnvErrors(t *testing.T) {
+	_, err := baseEnv(&config.Injector{EnvMode: "nope"}, nil)
+	assert.Error(t, err)
+	_, err = baseEnv(&config.Injector{EnvDeny: []string{"["}}, nil)
+	assert.Error(t, err)
+}
+
+func TestSetBaseEnv(t *testing.T) {
+	m := Manager{injector: &config.Injector{
+		EnvMode:  config.EnvModeAllowlist,
+		EnvAllow: []string{"PATH", "REGION"},
+		Env:      []string{"REGION=eu-west-1", "DEBUG=true", "EMPTY="},
+	}}
+	assert.NoError(t, m.setBaseEnv([]string{"PATH=/usr/bin", "REGION=us-east-1", "HOME=/home/esi"}))
+	m.addEnv("DEBUG", "false")
+	assert.Equal(t, []string{"PATH=/usr/bin", "REGION=eu-west-1", "DEBUG=false", "EMPTY="}, m.env)
+
+	m.injector.Env = []string{"INVALID"}
+	assert.Error(t, m.setBaseEnv(nil))
+}
+
+func TestMergeEnvVars(t *testing.T) {
+	merged := mergeEnvVars(
+		[]EnvVar{{Key: "A", Value: "1"}, {Key: "B", Value: "2"}},
+		[]EnvVar{{Key: "B", Value: "3"}, {Key: "C", Value: "4"}},
+	)
+	assert.Equal(t, []EnvVar{{Key: "A", Value: "1"}, {Key: "B", Value: "3"}, {Key: "C", Value: "4"}}, merged)
+}
@@ -1,41 +1,61 @@
 package manager
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/mask"
 	"github.com/jon4hz/esi/shell"
 )
 
-func (m *Manager) addEnv(key, value string) {
-	m.env = append(m.env, fmt.Sprintf("%s=%s", key, value))
+// execute builds the command and runs it.
+func (m *Manager) execute(subshell bool) (int, error) {
+	var cmd *exec.Cmd
+	if subshell {
+		cmd = m.subshellCmd(m.args, m.env)
+	} else {
+		var err error
+		if cmd, err = m.singleCmd(m.args); err != nil {
+			return 1, err
+		}
+	}
+	return m.run(cmd)
 }
 
-func (m *Manager) executeSingleCommandWithEnvs(args []string) error {
+// singleCmd builds the command without a subshell.
+func (m *Manager) singleCmd(args []string) (*exec.Cmd, error) {
+	args, err := m.renderArgs(args)
+	if err != nil {
+		return nil, err
+	}
 	command := args[0]
 	argsForCommand := args[1:]
 
 	// TODO: debug log
 
 	cmd := exec.Command(command, argsForCommand...)
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdin = m.stdin
+	cmd.Stdout = m.stdout
+	cmd.Stderr = m.stderr
 	cmd.Env = m.env
 
-	_, err := m.execCmd(cmd)
-	return err
+	return cmd, nil
 }
 
-// ExecCommand executes the SSH command inside a subshell. If no shell could be determined,
-// this function will try to execute ssh directly.
-func (m *Manager) execSubshell(args []string, env []string) (int, error) {
+// subshellCmd builds the command to execute inside a subshell. If no shell could be determined,
+// the command is executed directly.
+func (m *Manager) subshellCmd(args []string, env []string) *exec.Cmd {
 	shell := m.subShellCmd()
 	var cmd *exec.Cmd
 
@@ -43,7 +63,7 @@ func (m *Manager) execSubshell(args []string, env []string) (int, error) {
 	_, err := exec.LookPath(shell[0])
 	if err != nil {
 		// if we can't find the shell, just execute the command directly
-		log.Warn("Shell not found in PATH. Executing \"ssh\" directly.")
+		log.Warn("Shell not found in PATH. Executing the command directly.")
 		cmd = exec.Command(args[0], args[1:]...) // #nosec G204
 	} else {
 		subCmd := m.buildExecCmd(args)
@@ -54,78 +74,219 @@ func (m *Manager) execSubshell(args []string, env []string) (int, error) {
 		cmd = exec.Command(shell[0], args...) // #nosec G204
 	}
 
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	cmd.Stdin = m.stdin
+	cmd.Stdout = m.stdout
+	cmd.Stderr = m.stderr
 	cmd.Env = env
 
-	return m.execCmd(cmd)
+	return cmd
 }
 
-// buildExecCmd combines the given parts into a single command string.
-// If the parts contain quotes or backslashes, they will be escaped.
-func (m *Manager) buildExecCmd(parts []string) string {
-	if len(parts) == 0 {
-		return ""
+// run executes the command. In exec mode, esi is replaced by the command, unless it has to stay around
+// to cleanup afterwards. This way the command gets esi's PID, signals and job control.
+func (m *Manager) run(cmd *exec.Cmd) (int, error) {
+	if m.exec && m.supervise {
+		log.Warn("Can't replace esi with the command, because esi has to cleanup tmp files, mask the output, watch the secrets or enforce a timeout.")
+	}
+	if m.exec && !m.supervise && cmd.Err == nil {
+		log.Debug("Replacing esi with command", "path", cmd.Path)
+		// only returns on error
+		err := replaceProcess(cmd)
+		log.Warn("Failed to replace esi with the command. Running it as child instead.", "err", err)
+	}
+	if m.mask {
+		stdout, stderr := m.maskWriter(cmd.Stdout), m.maskWriter(cmd.Stderr)
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+		defer func() {
+			_ = stdout.Flush()
+			_ = stderr.Flush()
+		}()
 	}
+	return m.execCmd(cmd, m.signals)
+}
 
-	var s strings.Builder
-	for i, part := range parts {
-		if i > 0 {
-			s.WriteString(" ")
-		}
-		s.WriteString(shell.EscapeChars(part))
+// maskWriter returns a writer which masks the values of all fetched secrets.
+// If a secret changes in watch mode, its new value is masked as well.
+func (m *Manager) maskWriter(w io.Writer) *mask.Writer {
+	m.secretsMu.Lock()
+	defer m.secretsMu.Unlock()
+
+	values := make([]string, 0, len(m.secrets))
+	for _, s := range m.secrets {
+		values = append(values, s.Value)
 	}
+	mw := mask.NewWriter(w, values)
+	m.masks = append(m.masks, mw)
+	return mw
+}
+
+// argsMode defines how the args are combined into the command string for the subshell.
+type argsMode int
 
-	return s.String()
+const (
+	// argsEscape joins the args and escapes quotes and backslashes. It's the default for compatibility with older versions.
+	argsEscape argsMode = iota
+	// argsQuote quotes every arg, so that the subshell passes it verbatim to the command.
+	argsQuote
+	// argsRaw joins the args unmodified, so that a single arg is passed to the subshell as it is.
+	argsRaw
+)
+
+// buildExecCmd combines the given parts into a single command string for the subshell.
+// Except in quote mode, the subshell interprets the parts as a script.
+func (m *Manager) buildExecCmd(parts []string) string {
+	switch m.argsMode {
+	case argsRaw:
+		return strings.Join(parts, " ")
+	case argsQuote:
+		return m.quoteExecCmd(m.subShellCmd()[0], parts)
+	}
+	escaped := make([]string, 0, len(parts))
+	for _, part := range parts {
+		escaped = append(escaped, shell.EscapeChars(part))
+	}
+	return strings.Join(escaped, " ")
+}
+
+// quoteExecCmd quotes every part for the shell and joins them.
+func (m *Manager) quoteExecCmd(shellPath string, parts []string) string {
+	if isCmd(shellPath) {
+		log.Warn("CMD doesn't support quoting. Passing the command unmodified.")
+		return strings.Join(parts, " ")
+	}
+	sh, err := shell.Parse(shellPath)
+	if err != nil {
+		// all other shells are expected to be POSIX compatible
+		sh = shell.Sh
+	}
+	return shell.Join(sh, parts)
 }
 
-// subShellCmd returns the shell command which will be used to execute the actuall command.
+// subShellCmd returns the shell which will be used to execute the actual command
+// and the flag to pass the command string.
 func (m *Manager) subShellCmd() [2]string {
 	// default to sh -c
-	shell := [...]string{"sh", "-c"}
+	sh := [...]string{"sh", "-c"}
 
 	currentShell := os.Getenv("SHELL")
 	if currentShell != "" {
-		shell[0] = currentShell
-		log.Debug("Detected shell based on env:", "shell", shell)
+		sh[0] = currentShell
+		if s, err := shell.Parse(currentShell); err == nil {
+			sh[1] = shell.CommandFlag(s)
+		}
+		log.Debug("Detected shell based on env:", "shell", sh)
 	} else if runtime.GOOS == "windows" {
 		// if the SHELL env var is not set and we're on Windows, use cmd.exe
 		// The SHELL var should always be checked first, in case the user executes
-		// cyberark-ssh-utils from something like Git Bash.
-		shell = [...]string{"cmd", "/C"}
-		log.Debug("Falling back to CMD on windows:", "shell", shell)
+		// esi from something like Git Bash.
+		sh = [...]string{"cmd", "/C"}
+		log.Debug("Falling back to CMD on windows:", "shell", sh)
 	} else {
 		log.Debug("No shell detected. Using \"sh\"")
 	}
 
-	return shell
+	return sh
+}
+
+// isCmd reports whether the shell is cmd.exe.
+func isCmd(shellPath string) bool {
+	return strings.EqualFold(strings.TrimSuffix(filepath.Base(shellPath), ".exe"), "cmd")
 }
 
 // execCmd executes the command and waits for its termination.
-// Credit: infisical
-func (m *Manager) execCmd(cmd *exec.Cmd) (int, error) {
+// The command runs in its own process group and signals sent to esi are forwarded to that group.
+// If the command doesn't exit in time after esi was asked to terminate or the timeout expired, it gets killed.
+// Signals received from the signals channel are only forwarded.
+// It returns the exit code of the command.
+func (m *Manager) execCmd(cmd *exec.Cmd, signals <-chan os.Signal) (int, error) {
+	p := newProcess(cmd)
+	if m.pty && usePTY(cmd) {
+		if err := p.attachPTY(); err != nil {
+			log.Warn("Failed to allocate a pseudo terminal", "err", err)
+		}
+	}
+
 	sigChannel := make(chan os.Signal, 1)
-	signal.Notify(sigChannel)
-	if err := cmd.Start(); err != nil {
-		return 1, err
+	signal.Notify(sigChannel, forwardSignals...)
+	defer signal.Stop(sigChannel)
+
+	if err := p.start(); err != nil {
+		code := startExitCode(err)
+		return code, &ExitError{Code: code, Err: err}
+	}
+	if m.runDir != nil {
+		// the run dir must outlive esi, as long as the command is running
+		if err := m.runDir.AddPID(cmd.Process.Pid); err != nil {
+			log.Warn("Failed to record the command in the run dir", "err", err)
+		}
 	}
+
+	exited := make(chan struct{})
+	grace := killGracePeriod
 	go func() {
+		var kill *time.Timer
+		// nil, if there is no timeout
+		expired := m.expired
 		for {
-			sig := <-sigChannel
-			switch sig {
-			case os.Interrupt, syscall.SIGINT, syscall.SIGTERM:
-				m.cleanup()
+			var sig os.Signal
+			// only termination requests of the user or the timeout get enforced,
+			// the signals of the watch mode might just ask the command to reload
+			var enforce bool
+			select {
+			case sig = <-sigChannel:
+				enforce = isTermination(sig)
+			case sig = <-signals:
+			case <-expired:
+				// the channel stays closed, so only terminate once
+				sig, expired, enforce = syscall.SIGTERM, nil, true
+			case <-exited:
+				if kill != nil {
+					kill.Stop()
+				}
+				return
+			}
+			log.Debug("Forwarding signal", "signal", sig)
+			if err := p.signal(sig); err != nil {
+				log.Debug("Failed to forward signal", "signal", sig, "err", err)
+			}
+			if enforce && kill == nil {
+				kill = time.AfterFunc(grace, func() {
+					log.Warn("Command didn't exit in time. Killing it...", "grace", grace)
+					_ = p.signal(os.Kill)
+				})
 			}
-			_ = cmd.Process.Signal(sig) // process all sigs
 		}
 	}()
-	if err := cmd.Wait(); err != nil {
-		_ = cmd.Process.Signal(os.Kill)
 
-		waitStatus := cmd.ProcessState.Sys().(syscall.WaitStatus)
-		return waitStatus.ExitStatus(), fmt.Errorf("failed to wait for command termination: %v", err)
+	err := p.wait()
+	close(exited)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			_ = p.signal(os.Kill)
+			return 1, fmt.Errorf("failed to wait for command termination: %v", err)
+		}
+	}
+	return exitCode(cmd.ProcessState), nil
+}
+
+// startExitCode returns the exit code for a command, which couldn't be started.
+// Like shells do, 127 is returned if the command wasn't found and 126 if it isn't executable.
+func startExitCode(err error) int {
+	switch {
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return 127
+	case errors.Is(err, fs.ErrPermission), errors.Is(err, syscall.ENOEXEC):
+		return 126
+	}
+	return 1
+}
+
+// exitCode returns the exit code of the process.
+// Like shells do, 128+n is returned if the process was killed by signal n.
+func exitCode(state *os.ProcessState) int {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
 	}
-	waitStatus := cmd.ProcessState.Sys().(syscall.WaitStatus)
-	return waitStatus.ExitStatus(), nil
+	return state.ExitCode()
 }
@@ -1,11 +1,20 @@
 package manager
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"syscall"
 	"testing"
+	"time"
 
+	"github.com/charmbracelet/log"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBuildExecCmd(t *testing.T) {
@@ -55,3 +64,237 @@ func TestBuildExecCmd(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildExecCmdModes(t *testing.T) {
+	type testCase struct {
+		shell    string
+		mode     argsMode
+		input    []string
+		expected string
+	}
+
+	testCases := []testCase{
+		{
+			shell:    "/bin/bash",
+			mode:     argsRaw,
+			input:    []string{`echo "a b"`},
+			expected: `echo "a b"`,
+		},
+		{
+			shell:    "/bin/bash",
+			mode:     argsRaw,
+			input:    []string{"echo", `"a \"b\""`, "|", "cat"},
+			expected: `echo "a \"b\"" | cat`,
+		},
+		{
+			shell:    "/bin/bash",
+			mode:     argsQuote,
+			input:    []string{"echo", `$HOME; rm -rf *`, `it's`},
+			expected: `'echo' '$HOME; rm -rf *' 'it'\''s'`,
+		},
+		{
+			shell:    "/bin/dash",
+			mode:     argsQuote,
+			input:    []string{"printf", `%s\n`, "`id`"},
+			expected: `'printf' '%s\n' '` + "`id`" + `'`,
+		},
+		{
+			shell:    "/usr/bin/fish",
+			mode:     argsQuote,
+			input:    []string{"echo", `it's a \ test`},
+			expected: `'echo' 'it\'s a \\ test'`,
+		},
+		{
+			shell:    "pwsh",
+			mode:     argsQuote,
+			input:    []string{"Write-Output", `it's $env:HOME`},
+			expected: `& 'Write-Output' 'it''s $env:HOME'`,
+		},
+		{
+			shell:    "cmd.exe",
+			mode:     argsQuote,
+			input:    []string{"echo", "%PATH%"},
+			expected: `echo %PATH%`,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			t.Setenv("SHELL", tc.shell)
+			m := Manager{argsMode: tc.mode}
+			assert.Equal(t, tc.expected, m.buildExecCmd(tc.input))
+		})
+	}
+}
+
+func TestArgsArePassedToTheShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+	t.Setenv("SHELL", "/bin/sh")
+
+	type testCase struct {
+		mode     argsMode
+		input    []string
+		expected string
+	}
+
+	testCases := []testCase{
+		{
+			mode:     argsQuote,
+			input:    []string{"printf", `%s|`, `$HOME`, "a b", `it's`, "`id`", "*", `back\slash`, "semi;colon"},
+			expected: `$HOME|a b|it's|` + "`id`" + `|*|back\slash|semi;colon|`,
+		},
+		{
+			mode:     argsRaw,
+			input:    []string{`printf '%s|' "a b" 'c'`},
+			expected: `a b|c|`,
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			m := Manager{argsMode: tc.mode}
+			out, err := exec.Command("sh", "-c", m.buildExecCmd(tc.input)).Output()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(out))
+		})
+	}
+}
+
+func TestExecCmdExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	type testCase struct {
+		script   string
+		expected int
+	}
+
+	testCases := []testCase{
+		{script: "exit 0", expected: 0},
+		{script: "exit 3", expected: 3},
+		{script: "kill -KILL $$", expected: 128 + 9},
+		{script: "kill -TERM $$", expected: 128 + 15},
+	}
+
+	m := Manager{cleanup: func() {}}
+	for _, tc := range testCases {
+		t.Run(tc.script, func(t *testing.T) {
+			code, err := m.execCmd(exec.Command("sh", "-c", tc.script), nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, code)
+		})
+	}
+}
+
+func TestExecCmdStartFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires posix permissions")
+	}
+
+	notExecutable := filepath.Join(t.TempDir(), "script")
+	require.NoError(t, os.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0600))
+
+	type testCase struct {
+		name     string
+		cmd      *exec.Cmd
+		expected int
+	}
+
+	testCases := []testCase{
+		{name: "not found", cmd: exec.Command("esi-command-does-not-exist"), expected: 127},
+		{name: "missing path", cmd: exec.Command(filepath.Join(t.TempDir(), "missing")), expected: 127},
+		{name: "not executable", cmd: exec.Command(notExecutable), expected: 126},
+	}
+
+	m := Manager{cleanup: func() {}}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, err := m.execCmd(tc.cmd, nil)
+			assert.Equal(t, tc.expected, code)
+			var exitErr *ExitError
+			require.True(t, errors.As(err, &exitErr), "unexpected error: %v", err)
+			assert.Equal(t, tc.expected, exitErr.Code)
+			assert.Error(t, exitErr.Err)
+		})
+	}
+}
+
+// captureLog redirects the log output to a buffer for the duration of the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return &buf
+}
+
+func TestRunIgnoresExecWhenSupervising(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+	logs := captureLog(t)
+
+	// if esi was replaced, the test would never return
+	m := Manager{exec: true, supervise: true, cleanup: func() {}}
+	code, err := m.run(exec.Command("sh", "-c", "exit 3"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, code)
+	assert.Contains(t, logs.String(), "Can't replace esi with the command")
+}
+
+func TestRunWarnsIfExecFails(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires posix permissions")
+	}
+	logs := captureLog(t)
+
+	notExecutable := filepath.Join(t.TempDir(), "script")
+	require.NoError(t, os.WriteFile(notExecutable, []byte("#!/bin/sh\n"), 0600))
+
+	m := Manager{exec: true, cleanup: func() {}}
+	code, err := m.run(exec.Command(notExecutable))
+	assert.Error(t, err)
+	assert.Equal(t, 126, code)
+	assert.Contains(t, logs.String(), "WARN Failed to replace esi with the command")
+}
+
+func TestExecCmdSignals(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGHUP
+
+	m := Manager{cleanup: func() {}}
+	code, err := m.execCmd(exec.Command("sh", "-c", "sleep 10"), signals)
+	assert.NoError(t, err)
+	assert.Equal(t, 128+int(syscall.SIGHUP), code)
+}
+
+func TestExecCmdWatchSignalDoesntKill(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	grace := killGracePeriod
+	killGracePeriod = 100 * time.Millisecond
+	t.Cleanup(func() { killGracePeriod = grace })
+
+	signals := make(chan os.Signal, 1)
+	go func() {
+		// give the command time to install the trap
+		time.Sleep(300 * time.Millisecond)
+		signals <- syscall.SIGHUP
+	}()
+
+	// the command reloads on SIGHUP and keeps running way longer than the grace period
+	script := `trap 'echo reload' HUP; i=0; while [ $i -lt 15 ]; do sleep 0.1; i=$((i+1)); done; exit 7`
+	m := Manager{cleanup: func() {}}
+	code, err := m.execCmd(exec.Command("sh", "-c", script), signals)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, code)
+}
@@ -1,6 +1,7 @@
 package manager
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 
@@ -11,7 +12,20 @@ import (
 
 const min15 = 900
 
+// ErrNonInteractive is returned, if esi would have to prompt the user in non-interactive mode.
+var ErrNonInteractive = errors.New("prompt not allowed in non-interactive mode")
+
+// Authenticate connects to the secret server. If a token was set, it's used directly
+// and neither the local encryption password nor the keyring are involved.
 func (m *Manager) Authenticate(forceNewPasswd, forceNewToken bool) error {
+	if m.token != "" {
+		log.Debug("Using the provided token")
+		if err := m.connectSecretServer(m.token); err != nil {
+			return fmt.Errorf("failed to connect to tss: %w", err)
+		}
+		return nil
+	}
+
 	token, err := m.gatherCredentials(forceNewPasswd, forceNewToken)
 	if err != nil {
 		return err
@@ -78,7 +92,7 @@ func (m *Manager) gatherToken(password []byte, force bool) (token []byte, err er
 		var err error
 		token, err = crypto.Decrypt(token, password)
 		if err != nil {
-			if err := m.sKeyring.Unlink(passwordID); err != nil {
+			if err := m.unlinkPassword(); err != nil {
 				log.Warn("Failed to unlink faulty password", "err", err)
 			}
 			if strings.Contains(err.Error(), "message authentication failed") {
@@ -105,33 +119,65 @@ func (m *Manager) gatherToken(password []byte, force bool) (token []byte, err er
 }
 
 func (m *Manager) getTokenFromKeyring() ([]byte, error) {
-	return m.uKeyring.Get(tokenID)
+	k, err := m.userKeyring()
+	if err != nil {
+		return nil, err
+	}
+	return k.Get(tokenID)
 }
 
 func (m *Manager) storeTokenInKeyring(token []byte) error {
-	return m.uKeyring.Store(tokenID, token, m.cfg.SecretServer.TTL)
+	k, err := m.userKeyring()
+	if err != nil {
+		return err
+	}
+	return k.Store(tokenID, token, m.cfg.SecretServer.TTL)
 }
 
 func (m *Manager) getTokenFromForm() ([]byte, error) {
 	var token string
-	if err := forms.TokenInputForm(&token).Run(); err != nil {
+	if err := m.prompt("TSS API token", func() error { return forms.Token(&token) }); err != nil {
 		return nil, fmt.Errorf("failed to get input: %w", err)
 	}
 	return []byte(token), nil
 }
 
 func (m *Manager) getPasswordFromKeyring() ([]byte, error) {
-	return m.sKeyring.GetAndRefresh(passwordID, min15)
+	k, err := m.sessionKeyring()
+	if err != nil {
+		return nil, err
+	}
+	return k.GetAndRefresh(passwordID, min15)
 }
 
 func (m *Manager) storePasswordInKeyring(password []byte) error {
-	return m.sKeyring.Store(passwordID, password, min15)
+	k, err := m.sessionKeyring()
+	if err != nil {
+		return err
+	}
+	return k.Store(passwordID, password, min15)
+}
+
+func (m *Manager) unlinkPassword() error {
+	k, err := m.sessionKeyring()
+	if err != nil {
+		return err
+	}
+	return k.Unlink(passwordID)
 }
 
 func (m *Manager) getPasswordFromForm() ([]byte, error) {
 	var password string
-	if err := forms.PasswordInputForm(&password).Run(); err != nil {
+	if err := m.prompt("local encryption password", func() error { return forms.Password(&password) }); err != nil {
 		return nil, fmt.Errorf("failed to get input: %w", err)
 	}
 	return []byte(password), nil
 }
+
+// prompt asks the user for input, unless esi runs in non-interactive mode.
+func (m *Manager) prompt(what string, ask func() error) error {
+	if m.nonInteractive {
+		return fmt.Errorf("%w: can't ask for the %s", ErrNonInteractive, what)
+	}
+	return ask()
+}
@@ -0,0 +1,23 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/jon4hz/esi/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNonInteractive(t *testing.T) {
+	m := Manager{
+		cfg:            &config.Config{Groups: []*config.Group{{Name: "dev"}}},
+		nonInteractive: true,
+	}
+
+	_, err := m.getTokenFromForm()
+	assert.ErrorIs(t, err, ErrNonInteractive)
+
+	_, err = m.getPasswordFromForm()
+	assert.ErrorIs(t, err, ErrNonInteractive)
+
+	assert.ErrorIs(t, m.selectInjector(), ErrNonInteractive)
+}
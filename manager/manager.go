@@ -1,15 +1,22 @@
 package manager
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/jon4hz/esi/config"
 	"github.com/jon4hz/esi/forms"
 	"github.com/jon4hz/esi/keyring"
+	"github.com/jon4hz/esi/mask"
+	"github.com/jon4hz/esi/state"
+	"github.com/jon4hz/esi/tmpfile"
 	"github.com/jon4hz/tss-sdk-go/v2/server"
 )
 
@@ -18,6 +25,24 @@ const (
 	tokenID    = "esi:token"
 )
 
+// ExitError is returned by Run, if the command exited with a non-zero exit code.
+// If the command couldn't be started, Err is set and Code is 127 (not found) or 126 (not executable) like in shells.
+type ExitError struct {
+	Code int
+	Err  error
+}
+
+func (e *ExitError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("failed to start command: %v", e.Err)
+	}
+	return fmt.Sprintf("command exited with code %d", e.Code)
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
 type Manager struct {
 	cfg        *config.Config
 	server     *server.Server
@@ -28,17 +53,65 @@ type Manager struct {
 	secrets    []*config.Secret
 	injector   *config.Injector
 	currentUID string
-	cleanup    func()
-	cleanupMu  sync.Mutex
-	cleanDone  bool
+	// secretsMu guards the values of the secrets and the mask writers, because watch mode updates them
+	secretsMu sync.RWMutex
+	// masks are the writers masking the secrets in the output of the command
+	masks []*mask.Writer
+	// fetched contains the secrets, whose values were fetched successfully
+	fetched map[*config.Secret]bool
+	// watchdog is the writing end of the pipe the watchdog waits for
+	watchdog *os.File
+	// supervise is true, if esi has to wait for the child to exit
+	supervise bool
+	// exec is true, if esi should be replaced by the command, unless it has to supervise it
+	exec bool
+	// mask is true, if secret values should be masked in the output of the command
+	mask bool
+	// pty is true, if the command gets a pseudo terminal when its output is proxied
+	pty bool
+	// argsMode defines how the args are passed to the subshell
+	argsMode argsMode
+	// token is used to authenticate, instead of the token stored in the keyring
+	token string
+	// nonInteractive is true, if esi must not prompt the user
+	nonInteractive bool
+	// watchInterval is the interval in which secrets are fetched again, if watch mode is enabled
+	watchInterval time.Duration
+	// watchSignal is sent to the command, if the secrets changed. If nil, the command is restarted.
+	watchSignal os.Signal
+	// timeout is the maximum runtime of the command
+	timeout time.Duration
+	// timedOut is set, if the command was terminated after the timeout
+	timedOut atomic.Bool
+	// expired is closed, once the timeout expired
+	expired <-chan struct{}
+	// signals are sent to the running command
+	signals  chan os.Signal
+	tmpFiles []*tmpFile
+	// runDir holds the tmp files, if there are any
+	runDir *tmpfile.RunDir
+	// stdio of the command
+	stdin     io.Reader
+	stdout    io.Writer
+	stderr    io.Writer
+	prepared  bool
+	cleanup   func()
+	cleanupMu sync.Mutex
+	cleanDone bool
 }
 
-func New(cfg *config.Config, args []string, inj *config.Injector) (*Manager, error) {
+func New(cfg *config.Config, args []string, inj *config.Injector, opts ...Opt) (*Manager, error) {
 	m := Manager{
 		cfg:      cfg,
 		args:     args,
-		env:      os.Environ(),
 		injector: inj,
+		signals:  make(chan os.Signal, 1),
+		stdin:    os.Stdin,
+		stdout:   os.Stdout,
+		stderr:   os.Stderr,
+	}
+	for _, opt := range opts {
+		opt(&m)
 	}
 
 	user, err := user.Current()
@@ -47,63 +120,49 @@ func New(cfg *config.Config, args []string, inj *config.Injector) (*Manager, err
 	}
 	m.currentUID = user.Uid
 
-	m.sKeyring, err = keyring.New(keyring.WithKeyringType(keyring.SessionKeyring))
-	if err != nil {
-		return nil, err
-	}
-
-	m.uKeyring, err = keyring.New(keyring.WithKeyringType(keyring.UserKeyring))
-	if err != nil {
-		return nil, err
-	}
-
 	return &m, nil
 }
 
-func (m *Manager) Run(subshell bool) error {
-	if err := m.Authenticate(false, false); err != nil {
-		return err
-	}
-
-	if m.injector == nil {
-		var group *config.Group
-		if err := forms.GroupSelectForm(m.cfg.Groups, &group).Run(); err != nil {
-			return err
-		}
-
-		var injector *config.Injector
-		if err := forms.InjectorSelectForm(m.cfg.InjectorsByGroupName(group.Name), &injector).Run(); err != nil {
-			return err
+// sessionKeyring opens the session keyring on first use.
+// This way esi doesn't require a keyring, unless it has to store credentials.
+func (m *Manager) sessionKeyring() (*keyring.Keyring, error) {
+	if m.sKeyring == nil {
+		k, err := keyring.New(keyring.WithKeyringType(keyring.SessionKeyring))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open session keyring: %w", err)
 		}
-		m.injector = injector
-	}
-
-	const maxRetries = 3
-	var found int
-	requiredSecrets := m.requiredSecrets(m.injector)
-	if len(requiredSecrets) == 0 {
-		log.Fatal("No required secrets found. Aborting...")
+		m.sKeyring = k
 	}
+	return m.sKeyring, nil
+}
 
-	for i := 0; i < maxRetries; i++ {
-		found = m.fetchRequiredSecrets(requiredSecrets)
-		if found != 0 {
-			break
+// userKeyring opens the user keyring on first use.
+func (m *Manager) userKeyring() (*keyring.Keyring, error) {
+	if m.uKeyring == nil {
+		k, err := keyring.New(keyring.WithKeyringType(keyring.UserKeyring))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open user keyring: %w", err)
 		}
+		m.uKeyring = k
 	}
-	if found == 0 {
-		log.Fatal("Unable to fetch any secrets!", "err", "max retries exceeded")
+	return m.uKeyring, nil
+}
+
+func (m *Manager) Run(subshell bool) error {
+	if err := m.prepare(); err != nil {
+		return err
 	}
 
-	cleaners := m.deployTmpFiles(m.injector.Configs)
+	cleaners, err := m.deployTmpFiles(m.injector.Configs)
 	m.cleanup = func() {
 		m.cleanupMu.Lock()
 		if m.cleanDone {
 			m.cleanupMu.Unlock()
 			return
 		}
-		for _, c := range cleaners {
-			if path, err := c(); err != nil {
+		// cleanup in reverse order, so that the run dir is removed last
+		for i := len(cleaners) - 1; i >= 0; i-- {
+			if path, err := cleaners[i](); err != nil {
 				log.Warn("Cleanup failed. Please delete the tmpfile manually!", "path", path)
 			} else {
 				log.Info("Cleanup successful!", "path", path)
@@ -117,18 +176,174 @@ func (m *Manager) Run(subshell bool) error {
 		m.cleanup()
 	}()
 
+	if err != nil {
+		return err
+	}
+	limit := m.runtimeLimit()
+	m.supervise = m.mustSupervise(len(cleaners) > 0)
+
 	m.printSecrets(m.injector.Configs)
 
-	m.setEnvVars(m.injector.Configs)
+	if err := m.buildEnv(); err != nil {
+		return err
+	}
 
-	if subshell {
-		if _, err := m.execSubshell(m.args, m.env); err != nil {
-			return err
-		}
+	if limit > 0 {
+		stop := m.startTimeout(limit)
+		defer stop()
+	}
+
+	var code int
+	if m.watchInterval > 0 {
+		code, err = m.runWatched(subshell)
 	} else {
-		if err := m.executeSingleCommandWithEnvs(m.args); err != nil {
+		code, err = m.execute(subshell)
+	}
+	if err != nil {
+		return err
+	}
+	if m.timedOut.Load() {
+		return &ExitError{Code: timeoutExitCode}
+	}
+	if code != 0 {
+		return &ExitError{Code: code}
+	}
+	return nil
+}
+
+// mustSupervise reports whether esi has to stay around while the command is running.
+// This is the case for cleaning up tmp files, masking or proxying the output, watching the secrets and enforcing a timeout.
+func (m *Manager) mustSupervise(cleanup bool) bool {
+	_, isFile := m.stdout.(*os.File)
+	return cleanup || m.mask || !isFile || m.watchInterval > 0 || m.runtimeLimit() > 0
+}
+
+// Env fetches the secrets of the injector and returns the env vars it injects
+// without executing any command.
+func (m *Manager) Env() ([]EnvVar, error) {
+	if err := m.prepare(); err != nil {
+		return nil, err
+	}
+
+	for _, c := range m.injector.Configs {
+		if c.TmpFile || c.Stdout {
+			log.Warn("Only env vars can be exported to a shell. Ignoring tmp_file and stdout configs.", "injector", m.injector.Name)
+			break
+		}
+	}
+	vars, err := staticEnvVars(m.injector)
+	if err != nil {
+		return nil, err
+	}
+	return mergeEnvVars(vars, m.envVars(m.injector.Configs)), nil
+}
+
+// EnvKeys returns the names of the env vars the injector sets.
+// Unlike Env, it neither authenticates nor fetches any secrets.
+func (m *Manager) EnvKeys() ([]string, error) {
+	if err := m.selectInjector(); err != nil {
+		return nil, err
+	}
+
+	vars, err := staticEnvVars(m.injector)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(vars)+len(m.injector.Configs))
+	for _, e := range vars {
+		keys = append(keys, e.Key)
+	}
+	for _, c := range m.injector.Configs {
+		if c.EnvKey != "" {
+			keys = append(keys, c.EnvKey)
+		}
+	}
+	return keys, nil
+}
+
+// prepare makes sure an injector is selected, authenticates against the secret server
+// and fetches all secrets required by that injector.
+// If the manager is connected to the secret server already, it doesn't authenticate again.
+// Injectors without secrets don't authenticate at all, as long as they set static env vars
+// or don't inherit the env of esi.
+func (m *Manager) prepare() error {
+	if m.prepared {
+		return nil
+	}
+	if err := m.selectInjector(); err != nil {
+		return err
+	}
+
+	requiredSecrets := m.requiredSecrets(m.injector)
+	if len(requiredSecrets) == 0 {
+		if !modifiesEnv(m.injector) {
+			return errors.New("the injector neither requires any secrets nor sets any env vars")
+		}
+		log.Debug("The injector doesn't require any secrets")
+		m.prepared = true
+		return nil
+	}
+
+	if m.server == nil {
+		if err := m.Authenticate(false, false); err != nil {
 			return err
 		}
 	}
+	if err := m.fetchRequiredSecrets(requiredSecrets); err != nil {
+		return err
+	}
+	m.prepared = true
 	return nil
 }
+
+// modifiesEnv reports whether the injector changes the env of the command without any secrets.
+func modifiesEnv(inj *config.Injector) bool {
+	return len(inj.Env) > 0 || (inj.EnvMode != "" && inj.EnvMode != config.EnvModeInherit)
+}
+
+// selectInjector asks the user to select one or more injectors, if none was set. Multiple injectors are merged.
+// The injectors last used in the current directory are preselected and the choice is remembered.
+func (m *Manager) selectInjector() error {
+	if m.injector != nil {
+		return nil
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get working directory: %w", err)
+	}
+	last, err := state.LastInjectors(dir)
+	if err != nil {
+		log.Debug("Failed to load last injectors", "err", err)
+	}
+
+	var choices []forms.Choice
+	if err := m.prompt("injector", func() error { return forms.PickInjectors(m.cfg.Groups, last, &choices) }); err != nil {
+		return injectorPromptError(err)
+	}
+
+	injectors := make([]*config.Injector, 0, len(choices))
+	fqdns := make([]string, 0, len(choices))
+	for _, c := range choices {
+		injectors = append(injectors, c.Injector)
+		fqdns = append(fqdns, c.FQDN())
+	}
+	inj, err := config.MergeInjectors(injectors...)
+	if err != nil {
+		return fmt.Errorf("failed to merge injectors: %w", err)
+	}
+	m.injector = inj
+
+	if err := state.SetLastInjectors(dir, fqdns); err != nil {
+		log.Debug("Failed to store last injectors", "err", err)
+	}
+	return nil
+}
+
+// injectorPromptError explains how to select an injector without a prompt.
+func injectorPromptError(err error) error {
+	if errors.Is(err, forms.ErrNoTTY) || errors.Is(err, ErrNonInteractive) {
+		return fmt.Errorf("%w (set the injector with --injector or in the workspace file)", err)
+	}
+	return err
+}
@@ -0,0 +1,51 @@
+package manager
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jon4hz/esi/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMustSupervise(t *testing.T) {
+	type testCase struct {
+		name       string
+		cleanup    bool
+		mask       bool
+		stdout     io.Writer
+		watch      time.Duration
+		timeout    time.Duration
+		maxRuntime time.Duration
+		expected   bool
+	}
+
+	testCases := []testCase{
+		{name: "nothing to do"},
+		{name: "tmp files", cleanup: true, expected: true},
+		{name: "mask", mask: true, expected: true},
+		{name: "proxied output", stdout: &bytes.Buffer{}, expected: true},
+		{name: "watch", watch: time.Minute, expected: true},
+		{name: "timeout", timeout: time.Minute, expected: true},
+		{name: "max runtime", maxRuntime: time.Minute, expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := Manager{
+				injector:      &config.Injector{MaxRuntime: tc.maxRuntime},
+				stdout:        os.Stdout,
+				mask:          tc.mask,
+				watchInterval: tc.watch,
+				timeout:       tc.timeout,
+			}
+			if tc.stdout != nil {
+				m.stdout = tc.stdout
+			}
+			assert.Equal(t, tc.expected, m.mustSupervise(tc.cleanup))
+		})
+	}
+}
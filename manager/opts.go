@@ -0,0 +1,92 @@
+package manager
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+type Opt func(m *Manager)
+
+// WithMask enables masking of secret values in the output of the command.
+func WithMask(mask bool) Opt {
+	return func(m *Manager) {
+		m.mask = mask
+	}
+}
+
+// WithWatch fetches the secrets in the given interval. If they changed, the signal
+// is sent to the command. If the signal is nil, the command is restarted instead.
+func WithWatch(interval time.Duration, sig os.Signal) Opt {
+	return func(m *Manager) {
+		m.watchInterval = interval
+		m.watchSignal = sig
+	}
+}
+
+// WithTimeout terminates the command after the given duration. Zero disables the timeout.
+func WithTimeout(timeout time.Duration) Opt {
+	return func(m *Manager) {
+		m.timeout = timeout
+	}
+}
+
+// WithPTY allocates a pseudo terminal for the command, if its output is proxied (e.g. to mask secrets)
+// and esi runs in a terminal. This way interactive programs keep working.
+func WithPTY(pty bool) Opt {
+	return func(m *Manager) {
+		m.pty = pty
+	}
+}
+
+// WithQuotedArgs quotes every arg in shell mode, so that the subshell passes them verbatim to the command.
+// By default, the args are joined with escaped quotes and backslashes for compatibility and the subshell
+// interprets them as a script.
+func WithQuotedArgs(quote bool) Opt {
+	return func(m *Manager) {
+		if quote {
+			m.argsMode = argsQuote
+		}
+	}
+}
+
+// WithRawArgs passes the args unmodified to the subshell, which interprets them as a script.
+func WithRawArgs(raw bool) Opt {
+	return func(m *Manager) {
+		if raw {
+			m.argsMode = argsRaw
+		}
+	}
+}
+
+// WithExec replaces esi with the command, so that the command gets esi's PID, signals and job control.
+// It has no effect, if esi has to stay around to cleanup tmp files, mask the output, watch the secrets or enforce a timeout.
+func WithExec(exec bool) Opt {
+	return func(m *Manager) {
+		m.exec = exec
+	}
+}
+
+// WithStdio sets the stdio of the command. By default, the command uses the stdio of esi.
+func WithStdio(stdin io.Reader, stdout, stderr io.Writer) Opt {
+	return func(m *Manager) {
+		m.stdin = stdin
+		m.stdout = stdout
+		m.stderr = stderr
+	}
+}
+
+// WithToken authenticates with the given TSS API token.
+// The local encryption password and the keyring aren't used at all.
+func WithToken(token string) Opt {
+	return func(m *Manager) {
+		m.token = token
+	}
+}
+
+// WithNonInteractive makes esi fail instead of prompting the user, e.g. in CI pipelines.
+func WithNonInteractive(nonInteractive bool) Opt {
+	return func(m *Manager) {
+		m.nonInteractive = nonInteractive
+	}
+}
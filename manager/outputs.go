@@ -2,7 +2,6 @@ package manager
 
 import (
 	"fmt"
-	"os"
 	"strings"
 
 	"github.com/charmbracelet/log"
@@ -10,35 +9,69 @@ import (
 	"github.com/jon4hz/esi/tmpfile"
 )
 
-func (m *Manager) deployTmpFiles(injectors []*config.InjectorConfig) []func() (string, error) {
+func (m *Manager) deployTmpFiles(injectors []*config.InjectorConfig) ([]func() (string, error), error) {
 	var cleaners []func() (string, error)
+	var runDir *tmpfile.RunDir
 	for _, inj := range injectors {
 		if !inj.TmpFile {
 			continue
 		}
 
-		inj.Secrets = func() map[string]*config.Secret {
-			secrets := make(map[string]*config.Secret)
-			for _, s := range inj.TmpFileSecrets {
-				if secretByID := m.secretByID(s); secretByID != nil {
-					secrets[s] = secretByID
+		if runDir == nil {
+			var err error
+			if runDir, err = tmpfile.NewRunDir(m.currentUID); err != nil {
+				return cleaners, fmt.Errorf("failed to create run dir: %w", err)
+			}
+			log.Debug("Created run dir", "path", runDir.Path())
+			cleaners = append(cleaners, runDir.Cleanup)
+			m.runDir = runDir
+
+			if m.cfg.Watchdog {
+				if err := m.startWatchdog(runDir); err != nil {
+					log.Warn("Failed to start watchdog", "err", err)
 				}
 			}
-			return secrets
-		}()
+		}
 
-		tf, err := tmpfile.New(inj, m.currentUID)
-		if err != nil {
-			log.Warn("Failed to create tmpfile", "err", err)
-		} else {
-			log.Debug("Created tmpfile", "path", tf.Path(), "var", inj.TmpFileVar)
-			if inj.TmpFileVar != "" {
-				m.addEnv(inj.TmpFileVar, tf.Path())
+		inj.Secrets = make(map[string]*config.Secret)
+		for _, s := range inj.TmpFileSecrets {
+			secretByID := m.fetchedSecret(s)
+			if secretByID == nil {
+				return cleaners, fmt.Errorf("secret %q not found", s)
 			}
-			cleaners = append(cleaners, tf.Cleanup)
+			inj.Secrets[s] = secretByID
+		}
+
+		f, err := tmpfile.New(inj, runDir, m.secretValue)
+		if err != nil {
+			return cleaners, fmt.Errorf("failed to create tmpfile: %w", err)
 		}
+		log.Debug("Created tmpfile", "path", f.Path(), "var", inj.TmpFileVar)
+		tf := &tmpFile{TmpFile: f, cfg: inj}
+		m.tmpFiles = append(m.tmpFiles, tf)
+		// the file might be renewed, so don't bind the cleanup to the current one
+		cleaners = append(cleaners, func() (string, error) { return tf.Cleanup() })
 	}
-	return cleaners
+	return cleaners, nil
+}
+
+// tmpFile is a deployed tmp file and the injector config it was created from.
+type tmpFile struct {
+	*tmpfile.TmpFile
+	cfg *config.InjectorConfig
+}
+
+// renewTmpFiles renders all tmp files again.
+func (m *Manager) renewTmpFiles() error {
+	for _, tf := range m.tmpFiles {
+		f, err := tf.Renew()
+		if err != nil {
+			return fmt.Errorf("failed to renew tmpfile: %w", err)
+		}
+		tf.TmpFile = f
+		log.Debug("Renewed tmpfile", "path", f.Path())
+	}
+	return nil
 }
 
 func (m *Manager) printSecrets(injectors []*config.InjectorConfig) {
@@ -49,25 +82,39 @@ func (m *Manager) printSecrets(injectors []*config.InjectorConfig) {
 			log.Debug("Unable to find secret by ID!", "id", inj.StdoutSecret)
 			continue
 		}
-		if inj.Stdout && secret.Value != "" {
-			b.WriteString(secret.Value)
+		if value := m.value(secret); inj.Stdout && value != "" {
+			b.WriteString(value)
 			if i < len(injectors)-1 {
 				b.WriteString("\n\n")
 			}
 		}
 	}
-	fmt.Fprint(os.Stdout, b.String())
+	fmt.Fprint(m.stdout, b.String())
+}
+
+// EnvVar is an env var injected by esi.
+type EnvVar struct {
+	Key   string
+	Value string
 }
 
 func (m *Manager) setEnvVars(injectors []*config.InjectorConfig) {
+	for _, e := range m.envVars(injectors) {
+		m.addEnv(e.Key, e.Value)
+	}
+}
+
+func (m *Manager) envVars(injectors []*config.InjectorConfig) []EnvVar {
+	var vars []EnvVar
 	for _, inj := range injectors {
 		secret := m.secretByID(inj.EnvSecret)
 		if secret == nil {
 			log.Debug("Unable to find secret by ID!", "id", inj.EnvSecret)
 			continue
 		}
-		if inj.EnvKey != "" && secret.Value != "" {
-			m.addEnv(inj.EnvKey, secret.Value)
+		if value := m.value(secret); inj.EnvKey != "" && value != "" {
+			vars = append(vars, EnvVar{Key: inj.EnvKey, Value: value})
 		}
 	}
+	return vars
 }
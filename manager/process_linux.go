@@ -0,0 +1,218 @@
+package manager
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"golang.org/x/sys/unix"
+)
+
+// killGracePeriod is the time the command gets to exit after it was asked to terminate.
+var killGracePeriod = 10 * time.Second
+
+// cldStopped is the siginfo code of a stopped child (CLD_STOPPED in signal.h).
+const cldStopped = 5
+
+// jobControlSignals contains the job control signals, which are forwarded to the command.
+// On linux, job control is handled in wait.
+var jobControlSignals []os.Signal
+
+// process is a running command. If it takes over the terminal of esi, it runs in its own process group.
+type process struct {
+	cmd *exec.Cmd
+	// group reports whether the command runs in its own process group
+	group bool
+	// tty is the terminal the command owns, or -1 if it doesn't own one.
+	tty int
+	// pty is the pseudo terminal of the command, if one was allocated.
+	pty *pty
+}
+
+func newProcess(cmd *exec.Cmd) *process {
+	p := &process{cmd: cmd, tty: -1}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	// If esi is a foreground job on its own, hand the terminal over to the command, unless it doesn't read from it.
+	// Otherwise the command stays in esi's process group, so that it doesn't stop the other members of the job,
+	// e.g. the pager in "esi -- cmd | less".
+	if fd := int(os.Stdin.Fd()); cmd.Stdin == io.Reader(os.Stdin) && !cmd.SysProcAttr.Setsid && isForeground(fd) && isAloneInGroup() {
+		cmd.SysProcAttr.Setpgid = true
+		cmd.SysProcAttr.Foreground = true
+		cmd.SysProcAttr.Ctty = fd
+		p.group = true
+		p.tty = fd
+	}
+	return p
+}
+
+// isForeground reports whether esi is in the foreground process group of the terminal.
+func isForeground(fd int) bool {
+	pgrp, err := unix.IoctlGetInt(fd, unix.TIOCGPGRP)
+	return err == nil && pgrp == syscall.Getpgrp()
+}
+
+// isAloneInGroup reports whether esi leads its process group and no other process is a member of it.
+func isAloneInGroup() bool {
+	pid := os.Getpid()
+	pgrp := syscall.Getpgrp()
+	if pgrp != pid {
+		return false
+	}
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return false
+	}
+	for _, e := range entries {
+		other, err := strconv.Atoi(e.Name())
+		if err != nil || other == pid {
+			continue
+		}
+		if g, err := unix.Getpgid(other); err == nil && g == pgrp {
+			return false
+		}
+	}
+	return true
+}
+
+// attachPTY runs the command in a new session with a pseudo terminal.
+// The pty is proxied to the stdio of esi and the output is written to the stdout of the command.
+func (p *process) attachPTY() error {
+	t, err := openPTY(p.cmd.Stdout)
+	if err != nil {
+		return err
+	}
+	// the command gets its own session, so it can't join esi's process group or terminal
+	p.cmd.SysProcAttr.Setpgid = false
+	p.cmd.SysProcAttr.Foreground = false
+	p.group = true
+	p.tty = -1
+	t.attach(p.cmd)
+	p.pty = t
+	return nil
+}
+
+func (p *process) start() error {
+	if err := p.cmd.Start(); err != nil {
+		if p.pty != nil {
+			p.pty.slave.Close()  // nolint:errcheck
+			p.pty.master.Close() // nolint:errcheck
+		}
+		return err
+	}
+	if p.pty != nil {
+		p.pty.start()
+	}
+	if p.tty >= 0 {
+		// esi is in the background now. Changing the foreground process group
+		// from the background raises SIGTTOU, unless it's ignored.
+		signal.Ignore(syscall.SIGTTOU)
+	}
+	return nil
+}
+
+// signal sends the signal to the process group of the command, or only to the command, if it shares esi's group.
+// If the command has a pty, a SIGWINCH resizes the pty instead, which signals the command.
+func (p *process) signal(sig os.Signal) error {
+	if p.pty != nil && sig == syscall.SIGWINCH {
+		p.pty.resize()
+		return nil
+	}
+	s, ok := sig.(syscall.Signal)
+	if !ok || !p.group {
+		return p.cmd.Process.Signal(sig)
+	}
+	return syscall.Kill(-p.cmd.Process.Pid, s)
+}
+
+// wait waits for the command to exit. If the command runs in its own process group and gets stopped
+// (e.g. by ctrl+z), esi stops itself as well, so that the shell regains control over the terminal.
+// Otherwise the terminal stops and continues esi together with the command.
+func (p *process) wait() error {
+	defer func() {
+		if p.pty != nil {
+			p.pty.close()
+		}
+		if p.tty >= 0 {
+			if p.foreground() == p.cmd.Process.Pid {
+				p.setForeground(syscall.Getpgrp())
+			}
+			signal.Reset(syscall.SIGTTOU)
+		}
+	}()
+
+	if !p.group {
+		return p.cmd.Wait()
+	}
+	for {
+		var info unix.Siginfo
+		err := unix.Waitid(unix.P_PID, p.cmd.Process.Pid, &info, unix.WEXITED|unix.WSTOPPED|unix.WNOWAIT, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if err != nil || info.Code != cldStopped {
+			// the command exited, let exec reap it
+			return p.cmd.Wait()
+		}
+		// consume the stop event
+		if err := unix.Waitid(unix.P_PID, p.cmd.Process.Pid, &info, unix.WSTOPPED, nil); err != nil {
+			log.Debug("Failed to wait for stopped command", "err", err)
+		}
+		p.suspend()
+	}
+}
+
+// suspend stops esi until it gets continued by the shell, and continues the command afterwards.
+func (p *process) suspend() {
+	log.Debug("Command was stopped. Suspending esi...")
+	// take the terminal back, unless the command was running in the background (bg)
+	if p.tty >= 0 && p.foreground() == p.cmd.Process.Pid {
+		p.setForeground(syscall.Getpgrp())
+	}
+
+	// The stop doesn't necessarily take effect before kill returns,
+	// so wait for the SIGCONT of the shell before continuing the command.
+	cont := make(chan os.Signal, 1)
+	signal.Notify(cont, syscall.SIGCONT)
+	defer signal.Stop(cont)
+	if p.pty != nil {
+		p.pty.restore()
+	}
+	_ = syscall.Kill(os.Getpid(), syscall.SIGSTOP)
+	<-cont
+	if p.pty != nil {
+		p.pty.makeRaw()
+		p.pty.resize()
+	}
+
+	log.Debug("Continuing command...")
+	// only hand over the terminal if the shell continued us in the foreground (fg, not bg)
+	if p.tty >= 0 && p.foreground() == syscall.Getpgrp() {
+		p.setForeground(p.cmd.Process.Pid)
+	}
+	_ = p.signal(syscall.SIGCONT)
+}
+
+// foreground returns the foreground process group of the terminal.
+func (p *process) foreground() int {
+	pgrp, err := unix.IoctlGetInt(p.tty, unix.TIOCGPGRP)
+	if err != nil {
+		return -1
+	}
+	return pgrp
+}
+
+// setForeground makes pgrp the foreground process group of the terminal.
+func (p *process) setForeground(pgrp int) {
+	if err := unix.IoctlSetPointerInt(p.tty, unix.TIOCSPGRP, pgrp); err != nil {
+		log.Debug("Failed to set foreground process group", "pgrp", pgrp, "err", err)
+	}
+}
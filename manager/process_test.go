@@ -0,0 +1,67 @@
+package manager
+
+import (
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The scripts below signal esi (the test process) through $PPID, like a user would.
+
+func TestExecCmdKillsAfterGracePeriod(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	grace := killGracePeriod
+	killGracePeriod = 100 * time.Millisecond
+	t.Cleanup(func() { killGracePeriod = grace })
+
+	start := time.Now()
+	m := Manager{cleanup: func() {}}
+	code, err := m.execCmd(exec.Command("sh", "-c", `trap '' TERM; kill -TERM $PPID; exec sleep 5`), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 128+9, code)
+	assert.Less(t, time.Since(start), 4*time.Second)
+}
+
+func TestExecCmdDoesntCleanupBeforeExit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	marker := filepath.Join(t.TempDir(), "exited")
+	script := `trap 'sleep 0.2; touch "$1"; exit 3' TERM; kill -TERM $PPID; while :; do sleep 0.05; done`
+
+	var cleaned bool
+	m := Manager{cleanup: func() { cleaned = true }}
+	code, err := m.execCmd(exec.Command("sh", "-c", script, "sh", marker), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, code)
+	assert.FileExists(t, marker)
+	// the tmp files are cleaned up by Run, after the command exited
+	assert.False(t, cleaned)
+}
+
+func TestExecCmdKeepsSupervisingAfterInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	grace := killGracePeriod
+	killGracePeriod = 50 * time.Millisecond
+	t.Cleanup(func() { killGracePeriod = grace })
+
+	start := time.Now()
+	var cleaned bool
+	m := Manager{cleanup: func() { cleaned = true }}
+	code, err := m.execCmd(exec.Command("sh", "-c", `trap '' INT; kill -INT $PPID; sleep 0.3; exit 5`), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, code)
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+	assert.False(t, cleaned)
+}
@@ -0,0 +1,49 @@
+//go:build !linux && !windows
+
+package manager
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// killGracePeriod is the time the command gets to exit after it was asked to terminate.
+var killGracePeriod = 10 * time.Second
+
+// jobControlSignals contains the job control signals, which are forwarded to the command.
+var jobControlSignals = []os.Signal{syscall.SIGCONT}
+
+// process is a running command. It runs in esi's process group, so the terminal stops and continues both.
+// Process groups of their own are only supported on linux.
+type process struct {
+	cmd *exec.Cmd
+}
+
+func newProcess(cmd *exec.Cmd) *process {
+	return &process{cmd: cmd}
+}
+
+// attachPTY isn't supported on this platform.
+func (p *process) attachPTY() error {
+	return errors.New("pseudo terminals are only supported on linux")
+}
+
+// usePTY reports whether the command should get a pty, which is never the case on this platform.
+func usePTY(*exec.Cmd) bool {
+	return false
+}
+
+func (p *process) start() error {
+	return p.cmd.Start()
+}
+
+func (p *process) signal(sig os.Signal) error {
+	return p.cmd.Process.Signal(sig)
+}
+
+func (p *process) wait() error {
+	return p.cmd.Wait()
+}
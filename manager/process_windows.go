@@ -0,0 +1,44 @@
+//go:build windows
+
+package manager
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// killGracePeriod is the time the command gets to exit after it was asked to terminate.
+var killGracePeriod = 10 * time.Second
+
+// process is a running command. Process groups and job control aren't supported on windows.
+type process struct {
+	cmd *exec.Cmd
+}
+
+func newProcess(cmd *exec.Cmd) *process {
+	return &process{cmd: cmd}
+}
+
+// attachPTY isn't supported on this platform.
+func (p *process) attachPTY() error {
+	return errors.New("pseudo terminals are only supported on linux")
+}
+
+// usePTY reports whether the command should get a pty, which is never the case on this platform.
+func usePTY(*exec.Cmd) bool {
+	return false
+}
+
+func (p *process) start() error {
+	return p.cmd.Start()
+}
+
+func (p *process) signal(sig os.Signal) error {
+	return p.cmd.Process.Signal(sig)
+}
+
+func (p *process) wait() error {
+	return p.cmd.Wait()
+}
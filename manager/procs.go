@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/config"
+	"github.com/jon4hz/esi/prefix"
+)
+
+// procColors are used to tell the output of the processes apart.
+var procColors = []lipgloss.Color{"6", "3", "5", "2", "4", "1"}
+
+// Proc is a command run by RunAll with its own injector.
+type Proc struct {
+	Name     string
+	Command  string
+	Injector *config.Injector
+}
+
+// RunAll authenticates once and runs all processes concurrently in a subshell.
+// The output of each process is prefixed with its name. As soon as one process exits,
+// all others are terminated and esi cleans up after them.
+// The error of the process that exited first is returned.
+func RunAll(cfg *config.Config, procs []*Proc, opts ...Opt) error {
+	if len(procs) == 0 {
+		return errors.New("no processes to run")
+	}
+
+	auth, err := New(cfg, nil, nil, opts...)
+	if err != nil {
+		return err
+	}
+	if err := auth.Authenticate(false, false); err != nil {
+		return err
+	}
+
+	var width int
+	for _, p := range procs {
+		if len(p.Name) > width {
+			width = len(p.Name)
+		}
+	}
+
+	managers := make([]*Manager, 0, len(procs))
+	outputs := make([][2]*prefix.Writer, 0, len(procs))
+	for i, p := range procs {
+		style := lipgloss.NewStyle().Bold(true).Foreground(procColors[i%len(procColors)])
+		label := style.Render(fmt.Sprintf("%-*s |", width, p.Name)) + " "
+		stdout, stderr := prefix.NewWriter(os.Stdout, label), prefix.NewWriter(os.Stderr, label)
+
+		// the processes don't get a terminal, because they share it
+		procOpts := append(opts[:len(opts):len(opts)], WithStdio(nil, stdout, stderr), WithPTY(false))
+		// the processes run concurrently, so they must not share any state
+		m, err := New(cfg, []string{p.Command}, p.Injector.Clone(), procOpts...)
+		if err != nil {
+			return err
+		}
+		m.server = auth.server
+
+		// fetch the secrets one after another, in case the user has to authenticate again
+		log.Debug("Fetching secrets", "process", p.Name)
+		if err := m.prepare(); err != nil {
+			return fmt.Errorf("failed to prepare process %q: %w", p.Name, err)
+		}
+		managers = append(managers, m)
+		outputs = append(outputs, [2]*prefix.Writer{stdout, stderr})
+	}
+
+	type result struct {
+		name string
+		err  error
+	}
+	results := make(chan result, len(managers))
+	for i, m := range managers {
+		go func(name string, m *Manager, outputs [2]*prefix.Writer) {
+			err := m.Run(true)
+			for _, o := range outputs {
+				_ = o.Flush()
+			}
+			results <- result{name: name, err: err}
+		}(procs[i].Name, m, outputs[i])
+	}
+
+	first := <-results
+	log.Info("Process exited. Stopping all other processes...", "process", first.name)
+	for _, m := range managers {
+		m.sendSignal(syscall.SIGTERM)
+	}
+	for i := 1; i < len(managers); i++ {
+		r := <-results
+		log.Debug("Process stopped", "process", r.name, "err", r.err)
+	}
+	return first.err
+}
@@ -0,0 +1,153 @@
+package manager
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/muesli/cancelreader"
+	"golang.org/x/sys/unix"
+	"golang.org/x/term"
+)
+
+// ptyDrainTimeout is the time to wait for the remaining output of the pty after the command exited.
+// Background processes of the command might keep the pty open forever.
+const ptyDrainTimeout = time.Second
+
+// pty is a pseudo terminal between esi's terminal and the command.
+type pty struct {
+	master *os.File
+	slave  *os.File
+	out    io.Writer
+	// state is the state of esi's terminal before it was put into raw mode
+	state *term.State
+	// drained is closed, once all output of the command was copied
+	drained chan struct{}
+	// stdin reads the input of esi's terminal and can be canceled
+	stdin cancelreader.CancelReader
+}
+
+// openPTY allocates a new pseudo terminal. The output of the command is written to out.
+func openPTY(out io.Writer) (*pty, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pty: %w", err)
+	}
+	fd := int(master.Fd())
+	if err := unix.IoctlSetPointerInt(fd, unix.TIOCSPTLCK, 0); err != nil {
+		master.Close() // nolint:errcheck
+		return nil, fmt.Errorf("failed to unlock pty: %w", err)
+	}
+	n, err := unix.IoctlGetInt(fd, unix.TIOCGPTN)
+	if err != nil {
+		master.Close() // nolint:errcheck
+		return nil, fmt.Errorf("failed to get pty number: %w", err)
+	}
+	slave, err := os.OpenFile("/dev/pts/"+strconv.Itoa(n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close() // nolint:errcheck
+		return nil, fmt.Errorf("failed to open pty: %w", err)
+	}
+	return &pty{master: master, slave: slave, out: out, drained: make(chan struct{})}, nil
+}
+
+// attach makes the pty the controlling terminal and the stdio of the command.
+func (t *pty) attach(cmd *exec.Cmd) {
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = t.slave, t.slave, t.slave
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setsid = true
+	cmd.SysProcAttr.Setctty = true
+	cmd.SysProcAttr.Ctty = 0
+}
+
+// start proxies the terminal of esi and the pty. It must be called after the command was started.
+func (t *pty) start() {
+	// only the command must hold the slave, otherwise we never notice that it's closed
+	t.slave.Close() // nolint:errcheck
+
+	t.resize()
+	t.makeRaw()
+
+	go func() {
+		defer close(t.drained)
+		// reading fails with EIO, as soon as all processes closed the slave
+		_, _ = io.Copy(t.out, t.master)
+	}()
+	stdin, err := cancelreader.NewReader(os.Stdin)
+	if err != nil {
+		log.Debug("Failed to read from terminal", "err", err)
+		return
+	}
+	t.stdin = stdin
+	go func() {
+		// the read is canceled when the pty is closed, so no input is lost after the command exited
+		_, _ = io.Copy(t.master, stdin)
+	}()
+}
+
+// makeRaw puts esi's terminal into raw mode, so that all input is passed to the pty unmodified.
+func (t *pty) makeRaw() {
+	state, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		log.Debug("Failed to put terminal into raw mode", "err", err)
+		return
+	}
+	t.state = state
+}
+
+// restore restores the state of esi's terminal.
+func (t *pty) restore() {
+	if t.state == nil {
+		return
+	}
+	if err := term.Restore(int(os.Stdin.Fd()), t.state); err != nil {
+		log.Debug("Failed to restore terminal", "err", err)
+	}
+	t.state = nil
+}
+
+// resize sets the size of the pty to the size of esi's terminal.
+func (t *pty) resize() {
+	ws, err := unix.IoctlGetWinsize(int(os.Stdin.Fd()), unix.TIOCGWINSZ)
+	if err != nil {
+		log.Debug("Failed to get terminal size", "err", err)
+		return
+	}
+	if err := unix.IoctlSetWinsize(int(t.master.Fd()), unix.TIOCSWINSZ, ws); err != nil {
+		log.Debug("Failed to set pty size", "err", err)
+	}
+}
+
+// close waits for the remaining output of the command, restores esi's terminal and closes the pty.
+func (t *pty) close() {
+	select {
+	case <-t.drained:
+	case <-time.After(ptyDrainTimeout):
+		log.Debug("Timed out waiting for the output of the command")
+	}
+	if t.stdin != nil {
+		t.stdin.Cancel()
+		t.stdin.Close() // nolint:errcheck
+	}
+	t.restore()
+	if err := t.master.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		log.Debug("Failed to close pty", "err", err)
+	}
+}
+
+// usePTY reports whether the command should get a pty. This is the case, if esi runs
+// in a terminal, but the output of the command is proxied (e.g. to mask secrets).
+func usePTY(cmd *exec.Cmd) bool {
+	if _, ok := cmd.Stdout.(*os.File); ok {
+		return false
+	}
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
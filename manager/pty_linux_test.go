@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"testing"
+
+	"github.com/jon4hz/esi/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTerminal makes a new pty the stdio of esi for the duration of the test.
+func fakeTerminal(t *testing.T) {
+	t.Helper()
+	term, err := openPTY(nil)
+	require.NoError(t, err)
+
+	stdin, stdout := os.Stdin, os.Stdout
+	os.Stdin, os.Stdout = term.slave, term.slave
+	t.Cleanup(func() {
+		os.Stdin, os.Stdout = stdin, stdout
+		term.slave.Close()  // nolint:errcheck
+		term.master.Close() // nolint:errcheck
+	})
+}
+
+func TestMaskedCommandGetsPTY(t *testing.T) {
+	fakeTerminal(t)
+
+	var out bytes.Buffer
+	m := Manager{
+		mask:    true,
+		pty:     true,
+		secrets: []*config.Secret{{ID: "db", Value: "s3cret"}},
+		cleanup: func() {},
+	}
+	cmd := exec.Command("sh", "-c", `test -t 0 && test -t 1 && test -t 2 && echo "terminal s3cret"`)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, &out, &out
+	require.True(t, usePTY(cmd))
+
+	code, err := m.run(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, 0, code, "the command didn't get a terminal")
+	assert.Contains(t, out.String(), "terminal ***")
+	assert.NotContains(t, out.String(), "s3cret")
+}
+
+func TestCommandWithoutPTY(t *testing.T) {
+	fakeTerminal(t)
+
+	var out bytes.Buffer
+	m := Manager{mask: true, cleanup: func() {}}
+	cmd := exec.Command("sh", "-c", `test -t 1 || echo "no terminal"`)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, &out, &out
+
+	code, err := m.run(cmd)
+	require.NoError(t, err)
+	assert.Equal(t, 0, code)
+	assert.Equal(t, "no terminal\n", out.String())
+}
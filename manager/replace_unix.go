@@ -0,0 +1,14 @@
+//go:build !windows
+
+package manager
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// replaceProcess replaces the current process with the command using execve.
+// It only returns if the command couldn't be executed.
+func replaceProcess(cmd *exec.Cmd) error {
+	return syscall.Exec(cmd.Path, cmd.Args, cmd.Env) // #nosec G204
+}
@@ -0,0 +1,13 @@
+//go:build windows
+
+package manager
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// replaceProcess isn't supported on windows.
+func replaceProcess(_ *exec.Cmd) error {
+	return errors.New("replacing the process isn't supported on windows")
+}
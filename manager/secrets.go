@@ -7,6 +7,8 @@ import (
 
 	"github.com/charmbracelet/log"
 	"github.com/jon4hz/esi/config"
+	"github.com/jon4hz/esi/tmpl"
+	"github.com/jon4hz/esi/transform"
 	"github.com/jon4hz/tss-sdk-go/v2/server"
 )
 
@@ -26,50 +28,109 @@ func (m *Manager) connectSecretServer(token string) error {
 	return nil
 }
 
+// requiredSecrets returns copies of all secrets the injector needs. Every manager fetches the values
+// into its own copies, because managers of esi run might fetch the same secret concurrently.
 func (m *Manager) requiredSecrets(inj *config.Injector) []*config.Secret {
 	requiredSecrets := make([]*config.Secret, 0)
+	seen := make(map[*config.Secret]bool)
+	require := func(id string) {
+		secret := m.cfg.SecretByID(id)
+		if secret == nil || seen[secret] {
+			return
+		}
+		seen[secret] = true
+		cp := *secret
+		requiredSecrets = append(requiredSecrets, &cp)
+	}
 	for _, c := range inj.Configs {
 		if c.EnvSecret != "" {
-			secret := m.cfg.SecretByID(c.EnvSecret)
-			if secret != nil {
-				requiredSecrets = append(requiredSecrets, secret)
-			}
+			require(c.EnvSecret)
 		}
 		if c.StdoutSecret != "" {
-			secret := m.cfg.SecretByID(c.StdoutSecret)
-			if secret != nil {
-				requiredSecrets = append(requiredSecrets, secret)
-			}
+			require(c.StdoutSecret)
 		}
-		if len(c.TmpFileSecrets) != 0 {
-			for _, s := range c.TmpFileSecrets {
-				secret := m.cfg.SecretByID(s)
-				if secret != nil {
-					requiredSecrets = append(requiredSecrets, secret)
-				}
+		for _, id := range c.TmpFileSecrets {
+			require(id)
+		}
+		if c.TmpFile {
+			for _, id := range templateSecretIDs(c) {
+				require(id)
 			}
 		}
 	}
+	if inj.AllowArgvSecrets {
+		for _, id := range argvSecretIDs(m.args) {
+			require(id)
+		}
+	}
 	return requiredSecrets
 }
 
+// templateSecretIDs returns the IDs of the secrets referenced by the secret function in the tmp file template.
+func templateSecretIDs(c *config.InjectorConfig) []string {
+	text, err := c.Template()
+	if err != nil {
+		log.Warn("Failed to load template", "err", err)
+		return nil
+	}
+	ids, err := tmpl.SecretIDs(text)
+	if err != nil {
+		log.Warn("Failed to parse template", "err", err)
+		return nil
+	}
+	return ids
+}
+
 func (m *Manager) fetchSecret(s *config.Secret) error {
+	value, err := m.fetchValue(s)
+	if err != nil {
+		return err
+	}
+	m.setValue(s, value)
+	return nil
+}
+
+// fetchValue fetches the current value of the secret without storing it.
+func (m *Manager) fetchValue(s *config.Secret) (string, error) {
 	if m.server == nil {
-		return errors.New("no server configured")
+		return "", errors.New("no server configured")
 	}
 	secret, err := m.server.Secret(s.SecretID)
 	if err != nil {
-		return err
+		return "", err
 	}
 	value, ok := secret.Field(s.Field)
 	if !ok {
-		return fmt.Errorf("field %q does not exist", s.Field)
+		return "", fmt.Errorf("field %q does not exist", s.Field)
 	}
+	return transform.Apply(value, s.Transforms)
+}
+
+// setValue stores the value of the secret and masks it in the output of the command.
+func (m *Manager) setValue(s *config.Secret, value string) {
+	m.secretsMu.Lock()
+	defer m.secretsMu.Unlock()
+
 	s.Value = value
-	return nil
+	if m.fetched == nil {
+		m.fetched = make(map[*config.Secret]bool)
+	}
+	m.fetched[s] = true
+	for _, w := range m.masks {
+		w.Add(value)
+	}
+}
+
+// value returns the current value of the secret.
+func (m *Manager) value(s *config.Secret) string {
+	m.secretsMu.RLock()
+	defer m.secretsMu.RUnlock()
+	return s.Value
 }
 
-func (m *Manager) fetchRequiredSecrets(secrets []*config.Secret) int {
+// fetchRequiredSecrets fetches the values of the secrets. If the token was rejected,
+// the user has to authenticate again.
+func (m *Manager) fetchRequiredSecrets(secrets []*config.Secret) error {
 	for _, s := range secrets {
 		if err := m.fetchSecret(s); err != nil {
 			if strings.Contains(strings.ToLower(err.Error()), "forbidden") {
@@ -77,15 +138,15 @@ func (m *Manager) fetchRequiredSecrets(secrets []*config.Secret) int {
 				if err := m.Authenticate(false, true); err != nil {
 					log.Warn("Authentication failed", "err", err)
 				}
-				if err := m.fetchSecret(s); err == nil {
+				if err = m.fetchSecret(s); err == nil {
 					continue
 				}
 			}
-			log.Fatal("Failed to fetch secret!", "id", s.SecretID, "field", s.Field, "err", err)
+			return fmt.Errorf("failed to fetch secret %d (%s): %w", s.SecretID, s.Field, err)
 		}
 	}
 	m.secrets = secrets
-	return len(secrets)
+	return nil
 }
 
 func (m *Manager) secretByID(id string) *config.Secret {
@@ -96,3 +157,27 @@ func (m *Manager) secretByID(id string) *config.Secret {
 	}
 	return nil
 }
+
+// fetchedSecret returns the secret with the given ID, if its value was fetched successfully.
+func (m *Manager) fetchedSecret(id string) *config.Secret {
+	s := m.secretByID(id)
+	if s == nil {
+		return nil
+	}
+	m.secretsMu.RLock()
+	defer m.secretsMu.RUnlock()
+	if !m.fetched[s] {
+		return nil
+	}
+	return s
+}
+
+// secretValue returns the value of the fetched secret with the given ID. It's used to render templates,
+// so that a secret, which couldn't be fetched, fails rendering instead of producing an empty value.
+func (m *Manager) secretValue(id string) (string, bool) {
+	s := m.fetchedSecret(id)
+	if s == nil {
+		return "", false
+	}
+	return m.value(s), true
+}
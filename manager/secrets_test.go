@@ -0,0 +1,85 @@
+package manager
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jon4hz/esi/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequiredSecretsAreCopies(t *testing.T) {
+	cfg := &config.Config{Secrets: []*config.Secret{{ID: "db", SecretID: 1}}}
+	inj := &config.Injector{Configs: []*config.InjectorConfig{
+		{EnvKey: "DB_PASSWORD", EnvSecret: "db"},
+		{Stdout: true, StdoutSecret: "db"},
+	}}
+
+	a, b := &Manager{cfg: cfg}, &Manager{cfg: cfg}
+	secretsA, secretsB := a.requiredSecrets(inj), b.requiredSecrets(inj)
+	require.Len(t, secretsA, 1)
+	require.Len(t, secretsB, 1)
+	assert.NotSame(t, cfg.Secrets[0], secretsA[0])
+	assert.NotSame(t, secretsA[0], secretsB[0])
+	assert.Equal(t, "db", secretsA[0].ID)
+}
+
+func TestSecretValueRequiresFetch(t *testing.T) {
+	secret := &config.Secret{ID: "db", SecretID: 1}
+	m := &Manager{secrets: []*config.Secret{secret}}
+
+	_, ok := m.secretValue("db")
+	assert.False(t, ok, "a secret which wasn't fetched must not render as empty value")
+
+	m.setValue(secret, "s3cret")
+	value, ok := m.secretValue("DB")
+	assert.True(t, ok)
+	assert.Equal(t, "s3cret", value)
+
+	_, ok = m.secretValue("unknown")
+	assert.False(t, ok)
+}
+
+func TestPrepareWithoutSecrets(t *testing.T) {
+	type testCase struct {
+		name     string
+		injector *config.Injector
+		wantErr  bool
+	}
+
+	testCases := []testCase{
+		{name: "static env", injector: &config.Injector{Env: []string{"APP_ENV=dev"}}},
+		{name: "clean env", injector: &config.Injector{EnvMode: config.EnvModeClean}},
+		{name: "allowlist", injector: &config.Injector{EnvMode: config.EnvModeAllowlist, EnvAllow: []string{"PATH"}}},
+		{name: "nothing to inject", injector: &config.Injector{EnvMode: config.EnvModeInherit}, wantErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// without a server, esi would have to authenticate
+			m := &Manager{cfg: &config.Config{}, injector: tc.injector, nonInteractive: true}
+			err := m.prepare()
+			if tc.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Nil(t, m.server)
+		})
+	}
+}
+
+func TestPrepareFailsToFetch(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+
+	cfg := &config.Config{
+		SecretServer: &config.SecretServer{URL: srv.URL},
+		Secrets:      []*config.Secret{{ID: "db", SecretID: 1, Field: "password"}},
+	}
+	m := &Manager{cfg: cfg, injector: &config.Injector{Configs: []*config.InjectorConfig{{EnvKey: "DB", EnvSecret: "db"}}}}
+	require.NoError(t, m.connectSecretServer("token"))
+	assert.Error(t, m.prepare())
+}
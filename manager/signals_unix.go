@@ -0,0 +1,37 @@
+//go:build !windows
+
+package manager
+
+import (
+	"os"
+	"syscall"
+)
+
+// signalNames contains the signals which can be sent to the command by name.
+var signalNames = map[string]os.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"TERM": syscall.SIGTERM,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+}
+
+// forwardSignals contains all signals which are forwarded to the command.
+// Signals like SIGCHLD, SIGURG or SIGPIPE are meant for esi itself.
+var forwardSignals = append([]os.Signal{
+	syscall.SIGINT,
+	syscall.SIGTERM,
+	syscall.SIGHUP,
+	syscall.SIGQUIT,
+	syscall.SIGUSR1,
+	syscall.SIGUSR2,
+	syscall.SIGWINCH,
+}, jobControlSignals...)
+
+// isTermination reports whether the signal asks the command to terminate.
+// SIGINT isn't considered, because interactive programs often use it to cancel the current operation.
+// It's only forwarded and esi keeps supervising the command until it exits.
+func isTermination(sig os.Signal) bool {
+	return sig == syscall.SIGTERM || sig == syscall.SIGHUP
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package manager
+
+import (
+	"os"
+	"syscall"
+)
+
+// signalNames contains the signals which can be sent to the command by name.
+var signalNames = map[string]os.Signal{
+	"INT":  os.Interrupt,
+	"KILL": os.Kill,
+	"TERM": syscall.SIGTERM,
+}
+
+// forwardSignals contains all signals which are forwarded to the command.
+var forwardSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// isTermination reports whether the signal asks the command to terminate.
+func isTermination(sig os.Signal) bool {
+	return sig == syscall.SIGTERM
+}
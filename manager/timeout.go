@@ -0,0 +1,44 @@
+package manager
+
+import (
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// timeoutExitCode is returned, if the command was terminated after the timeout (like timeout(1) does).
+const timeoutExitCode = 124
+
+// runtimeLimit returns the maximum runtime of the command, which is the lower one of
+// the timeout and the max runtime of the injector. Zero means no limit.
+func (m *Manager) runtimeLimit() time.Duration {
+	limit := m.timeout
+	if maxRuntime := m.injector.MaxRuntime; maxRuntime > 0 && (limit == 0 || maxRuntime < limit) {
+		limit = maxRuntime
+	}
+	return limit
+}
+
+// startTimeout terminates the command after the given duration.
+// Once expired is closed, every command started afterwards is terminated as well, e.g. if watch mode restarts it.
+// The returned function stops the timer.
+func (m *Manager) startTimeout(limit time.Duration) func() bool {
+	expired := make(chan struct{})
+	m.expired = expired
+	timer := time.AfterFunc(limit, func() {
+		log.Warn("Command timed out. Terminating it...", "timeout", limit)
+		m.timedOut.Store(true)
+		close(expired)
+	})
+	return timer.Stop
+}
+
+// sendSignal sends the signal to the running command.
+// It never blocks, because the command might have exited already.
+func (m *Manager) sendSignal(sig os.Signal) {
+	select {
+	case m.signals <- sig:
+	default:
+	}
+}
@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/jon4hz/esi/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuntimeLimit(t *testing.T) {
+	type testCase struct {
+		name       string
+		timeout    time.Duration
+		maxRuntime time.Duration
+		expected   time.Duration
+	}
+
+	testCases := []testCase{
+		{name: "none"},
+		{name: "timeout", timeout: time.Hour, expected: time.Hour},
+		{name: "max runtime", maxRuntime: time.Hour, expected: time.Hour},
+		{name: "timeout is lower", timeout: time.Minute, maxRuntime: time.Hour, expected: time.Minute},
+		{name: "max runtime is lower", timeout: time.Hour, maxRuntime: time.Minute, expected: time.Minute},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := Manager{timeout: tc.timeout, injector: &config.Injector{MaxRuntime: tc.maxRuntime}}
+			assert.Equal(t, tc.expected, m.runtimeLimit())
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	m := Manager{signals: make(chan os.Signal, 1)}
+	stop := m.startTimeout(50 * time.Millisecond)
+	defer stop()
+
+	start := time.Now()
+	_, err := m.execCmd(exec.Command("sh", "-c", "sleep 10"), m.signals)
+	assert.NoError(t, err)
+	assert.True(t, m.timedOut.Load())
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestTimeoutBeforeStart(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a posix shell")
+	}
+
+	m := Manager{signals: make(chan os.Signal, 1)}
+	stop := m.startTimeout(time.Millisecond)
+	defer stop()
+	assert.Eventually(t, m.timedOut.Load, time.Second, time.Millisecond)
+
+	// like watch mode does before a restart
+	select {
+	case <-m.signals:
+	default:
+	}
+
+	start := time.Now()
+	_, err := m.execCmd(exec.Command("sh", "-c", "sleep 10"), m.signals)
+	assert.NoError(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
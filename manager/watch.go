@@ -0,0 +1,106 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+// ParseSignal parses the name of a signal like "SIGHUP" or "hup".
+func ParseSignal(name string) (os.Signal, error) {
+	if sig, ok := signalNames[strings.TrimPrefix(strings.ToUpper(name), "SIG")]; ok {
+		return sig, nil
+	}
+	return nil, fmt.Errorf("unknown signal %q", name)
+}
+
+// runWatched runs the command and watches its secrets. If they change, the command
+// gets the watch signal or is restarted gracefully, if no signal is configured.
+func (m *Manager) runWatched(subshell bool) (int, error) {
+	for {
+		if m.timedOut.Load() {
+			return timeoutExitCode, nil
+		}
+		var restart atomic.Bool
+		// drop signals meant for the previous command
+		select {
+		case <-m.signals:
+		default:
+		}
+
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			m.watchSecrets(stop, func() {
+				if m.watchSignal != nil {
+					log.Info("Secrets changed. Signaling command...", "signal", m.watchSignal)
+					m.sendSignal(m.watchSignal)
+					return
+				}
+				log.Info("Secrets changed. Restarting command...")
+				restart.Store(true)
+				m.sendSignal(syscall.SIGTERM)
+			})
+		}()
+
+		code, err := m.execute(subshell)
+		close(stop)
+		<-done
+		if err != nil || !restart.Load() || m.timedOut.Load() {
+			return code, err
+		}
+	}
+}
+
+// watchSecrets fetches the secrets in the watch interval until stop is closed.
+// If they changed, the tmp files and the env are updated before changed is called.
+func (m *Manager) watchSecrets(stop <-chan struct{}, changed func()) {
+	ticker := time.NewTicker(m.watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		log.Debug("Checking secrets for changes...")
+		if !m.refreshSecrets() {
+			continue
+		}
+		if err := m.renewTmpFiles(); err != nil {
+			log.Error("Failed to update tmp files", "err", err)
+			continue
+		}
+		if err := m.buildEnv(); err != nil {
+			log.Error("Failed to update env", "err", err)
+			continue
+		}
+		changed()
+	}
+}
+
+// refreshSecrets fetches all secrets again and reports whether any value changed.
+// If a secret can't be fetched, its previous value is kept.
+func (m *Manager) refreshSecrets() bool {
+	var changed bool
+	for _, s := range m.secrets {
+		value, err := m.fetchValue(s)
+		if err != nil {
+			log.Warn("Failed to refresh secret", "id", s.SecretID, "field", s.Field, "err", err)
+			continue
+		}
+		if value != m.value(s) {
+			log.Debug("Secret changed", "id", s.ID)
+			m.setValue(s, value)
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,62 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"syscall"
+	"testing"
+
+	"github.com/jon4hz/esi/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSignal(t *testing.T) {
+	for _, name := range []string{"SIGTERM", "sigterm", "TERM", "term"} {
+		sig, err := ParseSignal(name)
+		assert.NoError(t, err)
+		assert.Equal(t, syscall.SIGTERM, sig)
+	}
+
+	_, err := ParseSignal("SIGNOPE")
+	assert.Error(t, err)
+}
+
+// secretServer fakes the secret server. It returns the current value as the password field of every secret.
+func secretServer(t *testing.T, value *atomic.Value) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"Items": [{"Slug": "password", "ItemValue": %q}]}`, value.Load())
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRefreshSecretsMasksNewValues(t *testing.T) {
+	var value atomic.Value
+	value.Store("old-s3cret")
+	srv := secretServer(t, &value)
+
+	m := &Manager{cfg: &config.Config{SecretServer: &config.SecretServer{URL: srv.URL}}}
+	require.NoError(t, m.connectSecretServer("token"))
+	require.NoError(t, m.fetchRequiredSecrets([]*config.Secret{{ID: "db", SecretID: 1, Field: "password"}}))
+
+	var out bytes.Buffer
+	w := m.maskWriter(&out)
+	assert.False(t, m.refreshSecrets())
+
+	value.Store("new-s3cret")
+	assert.True(t, m.refreshSecrets())
+	v, ok := m.secretValue("db")
+	assert.True(t, ok)
+	assert.Equal(t, "new-s3cret", v)
+
+	_, err := w.Write([]byte("old-s3cret new-s3cret\n"))
+	require.NoError(t, err)
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "*** ***\n", out.String())
+}
@@ -0,0 +1,45 @@
+package manager
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/tmpfile"
+)
+
+// startWatchdog starts a detached esi process, which removes the run dir
+// as soon as esi and the child are gone, even if esi gets killed.
+// The watchdog waits until the writing end of a pipe is closed, which is only held by esi.
+// Afterwards it waits for the processes recorded in the run dir.
+func (m *Manager) startWatchdog(runDir *tmpfile.RunDir) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find executable: %w", err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to create pipe: %w", err)
+	}
+	defer r.Close()
+
+	cmd := exec.Command(exe, "watchdog", runDir.Path()) // #nosec G204
+	cmd.Stdin = r
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		w.Close() // nolint:errcheck
+		return fmt.Errorf("failed to start watchdog: %w", err)
+	}
+	log.Debug("Started watchdog", "pid", cmd.Process.Pid)
+
+	// esi never waits for the watchdog
+	if err := cmd.Process.Release(); err != nil {
+		log.Debug("Failed to release watchdog", "err", err)
+	}
+
+	// the pipe is closed by the kernel, once esi is gone
+	m.watchdog = w
+	return nil
+}
@@ -0,0 +1,132 @@
+package mask
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+)
+
+const (
+	// Replacement replaces secret values in the output.
+	Replacement = "***"
+	// MinLength is the minimum length of a value to be masked.
+	// Shorter values would redact too much unrelated output.
+	MinLength = 4
+)
+
+// Variants returns the secret and its base64 and URL encoded forms.
+func Variants(secret string) []string {
+	encodings := []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	}
+	variants := []string{secret}
+	for _, enc := range encodings {
+		variants = append(variants, enc.EncodeToString([]byte(secret)))
+	}
+	return append(variants, url.QueryEscape(secret), url.PathEscape(secret))
+}
+
+// Writer replaces all occurrences of the secrets with Replacement before writing to the underlying writer.
+// Data that might be the beginning of a secret is held back until the next write or Flush,
+// so that secrets split across multiple writes are masked as well.
+type Writer struct {
+	w       io.Writer
+	secrets [][]byte
+	seen    map[string]bool
+	mu      sync.Mutex
+	pending []byte
+}
+
+// NewWriter returns a writer that masks the secrets and all their variants.
+func NewWriter(w io.Writer, secrets []string) *Writer {
+	mw := &Writer{w: w, seen: make(map[string]bool)}
+	mw.Add(secrets...)
+	return mw
+}
+
+// Add masks the secrets and all their variants in all further writes, e.g. after a secret changed.
+func (w *Writer) Add(secrets ...string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, s := range secrets {
+		for _, v := range Variants(s) {
+			if len(v) < MinLength || w.seen[v] {
+				continue
+			}
+			w.seen[v] = true
+			w.secrets = append(w.secrets, []byte(v))
+		}
+	}
+	// prefer the longest match, if secrets overlap
+	sort.Slice(w.secrets, func(i, j int) bool {
+		return len(w.secrets[i]) > len(w.secrets[j])
+	})
+}
+
+// Write masks p and writes everything to the underlying writer that can't be part of a secret anymore.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, p...)
+	out, rest := w.mask(w.pending, false)
+	w.pending = append(w.pending[:0], rest...)
+	if _, err := w.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes all held back data to the underlying writer.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	out, _ := w.mask(w.pending, true)
+	w.pending = w.pending[:0]
+	if len(out) == 0 {
+		return nil
+	}
+	_, err := w.w.Write(out)
+	return err
+}
+
+// mask replaces the secrets in data. Unless final is set, it stops at the first
+// position where the remaining data is the beginning of a secret and returns the rest.
+func (w *Writer) mask(data []byte, final bool) (out, rest []byte) {
+	out = make([]byte, 0, len(data))
+	i := 0
+scan:
+	for i < len(data) {
+		for _, s := range w.secrets {
+			if bytes.HasPrefix(data[i:], s) {
+				out = append(out, Replacement...)
+				i += len(s)
+				continue scan
+			}
+		}
+		if !final && w.isPartial(data[i:]) {
+			break
+		}
+		out = append(out, data[i])
+		i++
+	}
+	return out, data[i:]
+}
+
+// isPartial reports whether data is the beginning of a secret.
+func (w *Writer) isPartial(data []byte) bool {
+	for _, s := range w.secrets {
+		if len(data) < len(s) && bytes.HasPrefix(s, data) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package mask
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	type testCase struct {
+		name     string
+		secrets  []string
+		input    string
+		expected string
+	}
+
+	testCases := []testCase{
+		{
+			name:     "raw",
+			secrets:  []string{"s3cret"},
+			input:    "password=s3cret\n",
+			expected: "password=***\n",
+		},
+		{
+			name:     "multiple occurrences",
+			secrets:  []string{"s3cret"},
+			input:    "s3cret s3cret",
+			expected: "*** ***",
+		},
+		{
+			name:     "base64",
+			secrets:  []string{"s3cret"},
+			input:    "token: " + base64.StdEncoding.EncodeToString([]byte("s3cret")),
+			expected: "token: ***",
+		},
+		{
+			name:     "url encoded",
+			secrets:  []string{"p@ss word&"},
+			input:    "https://example.com/?pw=" + url.QueryEscape("p@ss word&"),
+			expected: "https://example.com/?pw=***",
+		},
+		{
+			name:     "longest match",
+			secrets:  []string{"abcd", "abcdefgh"},
+			input:    "abcdefgh abcd",
+			expected: "*** ***",
+		},
+		{
+			name:     "too short",
+			secrets:  []string{"abc"},
+			input:    "abc",
+			expected: "abc",
+		},
+		{
+			name:     "partial match at the end",
+			secrets:  []string{"s3cret"},
+			input:    "no s3cr",
+			expected: "no s3cr",
+		},
+		{
+			name:     "no secrets",
+			input:    "hello world",
+			expected: "hello world",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := NewWriter(&out, tc.secrets)
+			n, err := w.Write([]byte(tc.input))
+			assert.NoError(t, err)
+			assert.Equal(t, len(tc.input), n)
+			assert.NoError(t, w.Flush())
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+}
+
+func TestWriterSplitWrites(t *testing.T) {
+	input := "user=admin password=s3cret! done"
+	for size := 1; size <= len(input); size++ {
+		var out bytes.Buffer
+		w := NewWriter(&out, []string{"s3cret!"})
+		for i := 0; i < len(input); i += size {
+			end := i + size
+			if end > len(input) {
+				end = len(input)
+			}
+			_, err := w.Write([]byte(input[i:end]))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, w.Flush())
+		assert.Equal(t, "user=admin password=*** done", out.String(), "chunk size %d", size)
+	}
+}
+
+func TestWriterHoldsBackPartialSecret(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out, []string{"s3cret"})
+	_, err := w.Write([]byte("echo s3c"))
+	assert.NoError(t, err)
+	assert.Equal(t, "echo ", out.String())
+	_, err = w.Write([]byte("ret\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "echo ***\n", out.String())
+}
+
+func TestWriterAdd(t *testing.T) {
+	var out bytes.Buffer
+	w := NewWriter(&out, []string{"old-s3cret"})
+	w.Add("new-s3cret")
+	_, err := w.Write([]byte("old-s3cret new-s3cret\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "*** ***\n", out.String())
+}
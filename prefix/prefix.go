@@ -0,0 +1,60 @@
+package prefix
+
+import (
+	"bytes"
+	"io"
+	"sync"
+)
+
+// Writer prefixes every line written to the underlying writer.
+// Incomplete lines are held back until they are completed or Flush is called,
+// so that lines of multiple writers sharing the same output don't get mixed up.
+type Writer struct {
+	w       io.Writer
+	prefix  []byte
+	mu      sync.Mutex
+	pending []byte
+}
+
+// NewWriter returns a writer that prefixes every line with the given prefix.
+func NewWriter(w io.Writer, prefix string) *Writer {
+	return &Writer{w: w, prefix: []byte(prefix)}
+}
+
+// Write writes all complete lines of p with the prefix to the underlying writer.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.pending = append(w.pending, p...)
+	var out []byte
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		out = append(out, w.prefix...)
+		out = append(out, w.pending[:i+1]...)
+		w.pending = w.pending[i+1:]
+	}
+	if len(out) > 0 {
+		if _, err := w.w.Write(out); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush writes the incomplete line, if there is one.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.pending) == 0 {
+		return nil
+	}
+	out := append(append(append([]byte{}, w.prefix...), w.pending...), '\n')
+	w.pending = nil
+	_, err := w.w.Write(out)
+	return err
+}
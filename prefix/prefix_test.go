@@ -0,0 +1,58 @@
+package prefix
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	type testCase struct {
+		name     string
+		writes   []string
+		expected string
+	}
+
+	testCases := []testCase{
+		{
+			name:     "single line",
+			writes:   []string{"hello\n"},
+			expected: "api | hello\n",
+		},
+		{
+			name:     "multiple lines",
+			writes:   []string{"hello\nworld\n"},
+			expected: "api | hello\napi | world\n",
+		},
+		{
+			name:     "split line",
+			writes:   []string{"hel", "lo\nwor", "ld\n"},
+			expected: "api | hello\napi | world\n",
+		},
+		{
+			name:     "incomplete line is flushed",
+			writes:   []string{"hello\nworld"},
+			expected: "api | hello\napi | world\n",
+		},
+		{
+			name:     "empty line",
+			writes:   []string{"\n"},
+			expected: "api | \n",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			w := NewWriter(&out, "api | ")
+			for _, s := range tc.writes {
+				n, err := w.Write([]byte(s))
+				assert.NoError(t, err)
+				assert.Equal(t, len(s), n)
+			}
+			assert.NoError(t, w.Flush())
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+}
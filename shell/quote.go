@@ -0,0 +1,138 @@
+package shell
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Shell is a shell dialect esi can generate code for.
+type Shell string
+
+const (
+	Sh         Shell = "sh"
+	Bash       Shell = "bash"
+	Zsh        Shell = "zsh"
+	Fish       Shell = "fish"
+	PowerShell Shell = "pwsh"
+)
+
+// Shells contains all supported shells.
+var Shells = []Shell{Sh, Bash, Zsh, Fish, PowerShell}
+
+// Parse returns the shell matching the given name or path, e.g. "zsh" or "/usr/bin/fish".
+func Parse(name string) (Shell, error) {
+	// windows paths might be used in e.g. git bash, so split at both separators
+	base := name[strings.LastIndexAny(name, `/\`)+1:]
+	base = strings.TrimSuffix(strings.ToLower(base), ".exe")
+	if base == "powershell" {
+		return PowerShell, nil
+	}
+	for _, s := range Shells {
+		if strings.EqualFold(base, string(s)) {
+			return s, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported shell: %q", name)
+}
+
+// Detect returns the shell set in the SHELL env var and falls back to bash.
+func Detect() Shell {
+	if s, err := Parse(os.Getenv("SHELL")); err == nil {
+		return s
+	}
+	return Bash
+}
+
+// Quote quotes s, so that the given shell reads it as a single literal word.
+func Quote(sh Shell, s string) string {
+	switch sh {
+	case Fish:
+		// fish allows escaping quotes and backslashes inside single quotes
+		s = strings.ReplaceAll(s, `\`, `\\`)
+		return "'" + strings.ReplaceAll(s, `'`, `\'`) + "'"
+	case PowerShell:
+		// PowerShell escapes single quotes by doubling them.
+		// Typographic single quotes are treated like regular ones.
+		return "'" + powerShellQuotes.Replace(s) + "'"
+	default:
+		// POSIX shells don't interpret anything inside single quotes,
+		// so we close the quotes, add an escaped quote and reopen them.
+		return "'" + strings.ReplaceAll(s, `'`, `'\''`) + "'"
+	}
+}
+
+var powerShellQuotes = strings.NewReplacer(
+	"'", "''",
+	"\u2018", "\u2018\u2018",
+	"\u2019", "\u2019\u2019",
+	"\u201a", "\u201a\u201a",
+	"\u201b", "\u201b\u201b",
+)
+
+// Join quotes all args and joins them to a command line,
+// which the given shell splits into exactly the same args again.
+func Join(sh Shell, args []string) string {
+	quoted := make([]string, 0, len(args))
+	for _, arg := range args {
+		quoted = append(quoted, Quote(sh, arg))
+	}
+	line := strings.Join(quoted, " ")
+	if sh == PowerShell && len(args) > 0 {
+		// PowerShell evaluates a quoted string at the start of a line as expression,
+		// so the call operator is required to run it as command.
+		return "& " + line
+	}
+	return line
+}
+
+// CommandFlag returns the flag which makes the shell execute a command string.
+func CommandFlag(sh Shell) string {
+	if sh == PowerShell {
+		return "-Command"
+	}
+	return "-c"
+}
+
+// keyPattern matches the env var names, which can be used unquoted in all supported shells.
+// POSIX shells don't allow anything else, fish and PowerShell would interpret other characters as code.
+var keyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// checkKey returns an error, if the key isn't a valid env var name in the given shell.
+func checkKey(sh Shell, key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("invalid env var name %q for %s: only letters, digits and underscores are allowed", key, sh)
+	}
+	return nil
+}
+
+// Export returns a statement that exports the env var in the given shell.
+func Export(sh Shell, key, value string) (string, error) {
+	if err := checkKey(sh, key); err != nil {
+		return "", err
+	}
+	switch sh {
+	case Fish:
+		return fmt.Sprintf("set -gx %s %s;", key, Quote(sh, value)), nil
+	case PowerShell:
+		return fmt.Sprintf("$env:%s = %s;", key, Quote(sh, value)), nil
+	default:
+		return fmt.Sprintf("export %s=%s;", key, Quote(sh, value)), nil
+	}
+}
+
+// Unset returns a statement that removes the env var in the given shell.
+func Unset(sh Shell, key string) (string, error) {
+	if err := checkKey(sh, key); err != nil {
+		return "", err
+	}
+	switch sh {
+	case Fish:
+		return fmt.Sprintf("set -e %s;", key), nil
+	case PowerShell:
+		return fmt.Sprintf("Remove-Item Env:%s -ErrorAction SilentlyContinue;", key), nil
+	default:
+		return fmt.Sprintf("unset %s;", key), nil
+	}
+}
@@ -0,0 +1,105 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuote(t *testing.T) {
+	type testCase struct {
+		shell    Shell
+		input    string
+		expected string
+	}
+
+	testCases := []testCase{
+		{shell: Bash, input: `test`, expected: `'test'`},
+		{shell: Bash, input: `it's`, expected: `'it'\''s'`},
+		{shell: Bash, input: `$HOME "quoted" \n`, expected: `'$HOME "quoted" \n'`},
+		{shell: Zsh, input: "multi\nline", expected: "'multi\nline'"},
+		{shell: Zsh, input: `a'b'c`, expected: `'a'\''b'\''c'`},
+		{shell: Fish, input: `test`, expected: `'test'`},
+		{shell: Fish, input: `it's`, expected: `'it\'s'`},
+		{shell: Fish, input: `back\slash`, expected: `'back\\slash'`},
+		{shell: Fish, input: `$HOME (cmd)`, expected: `'$HOME (cmd)'`},
+		{shell: PowerShell, input: `test`, expected: `'test'`},
+		{shell: PowerShell, input: `it's`, expected: `'it''s'`},
+		{shell: PowerShell, input: "it’s", expected: "'it’’s'"},
+		{shell: PowerShell, input: `$env:HOME "$(cmd)" C:	mp`, expected: `'$env:HOME "$(cmd)" C:	mp'`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.shell)+"/"+tc.input, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Quote(tc.shell, tc.input))
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	must := func(s string, err error) string {
+		require.NoError(t, err)
+		return s
+	}
+	assert.Equal(t, `export MY_SECRET='s3cr'\''t';`, must(Export(Bash, "MY_SECRET", `s3cr't`)))
+	assert.Equal(t, `export MY_SECRET='s3cr'\''t';`, must(Export(Zsh, "MY_SECRET", `s3cr't`)))
+	assert.Equal(t, `set -gx MY_SECRET 's3cr\'t';`, must(Export(Fish, "MY_SECRET", `s3cr't`)))
+	assert.Equal(t, `unset MY_SECRET;`, must(Unset(Bash, "MY_SECRET")))
+	assert.Equal(t, `set -e MY_SECRET;`, must(Unset(Fish, "MY_SECRET")))
+	assert.Equal(t, `$env:MY_SECRET = 's3cr''t';`, must(Export(PowerShell, "MY_SECRET", `s3cr't`)))
+	assert.Equal(t, `Remove-Item Env:MY_SECRET -ErrorAction SilentlyContinue;`, must(Unset(PowerShell, "MY_SECRET")))
+}
+
+func TestExportInvalidKey(t *testing.T) {
+	keys := []string{"", "1FOO", "FOO;rm -rf ~", "FOO BAR", "FOO=BAR", "$(id)", "FOO-BAR", "FOO\nid", "FÖÖ"}
+	for _, sh := range Shells {
+		for _, key := range keys {
+			_, err := Export(sh, key, "value")
+			assert.Error(t, err, "%s: %q", sh, key)
+			_, err = Unset(sh, key)
+			assert.Error(t, err, "%s: %q", sh, key)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	s, err := Parse("/usr/bin/zsh")
+	assert.NoError(t, err)
+	assert.Equal(t, Zsh, s)
+
+	s, err = Parse("fish")
+	assert.NoError(t, err)
+	assert.Equal(t, Fish, s)
+
+	s, err = Parse(`C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`)
+	assert.NoError(t, err)
+	assert.Equal(t, PowerShell, s)
+
+	_, err = Parse("/bin/tcsh")
+	assert.Error(t, err)
+}
+
+func TestJoin(t *testing.T) {
+	type testCase struct {
+		shell    Shell
+		input    []string
+		expected string
+	}
+
+	args := []string{"echo", "$HOME", "it's", `C:\tmp`, "a;b"}
+	testCases := []testCase{
+		{shell: Sh, input: args, expected: `'echo' '$HOME' 'it'\''s' 'C:\tmp' 'a;b'`},
+		{shell: Bash, input: args, expected: `'echo' '$HOME' 'it'\''s' 'C:\tmp' 'a;b'`},
+		{shell: Zsh, input: args, expected: `'echo' '$HOME' 'it'\''s' 'C:\tmp' 'a;b'`},
+		{shell: Fish, input: args, expected: `'echo' '$HOME' 'it\'s' 'C:\\tmp' 'a;b'`},
+		{shell: PowerShell, input: args, expected: `& 'echo' '$HOME' 'it''s' 'C:\tmp' 'a;b'`},
+		{shell: Bash, input: []string{""}, expected: `''`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(string(tc.shell), func(t *testing.T) {
+			assert.Equal(t, tc.expected, Join(tc.shell, tc.input))
+		})
+	}
+}
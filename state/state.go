@@ -0,0 +1,83 @@
+// Package state persists small bits of information between runs of esi,
+// e.g. the injector that was last used in a directory.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adrg/xdg"
+)
+
+// lastInjectorsFile maps directories to the comma separated fqdns of the injectors last used in them.
+const lastInjectorsFile = "esi/last-injectors.json"
+
+// LastInjectors returns the fqdns of the injectors last used in dir.
+func LastInjectors(dir string) ([]string, error) {
+	path, err := xdg.StateFile(lastInjectorsFile)
+	if err != nil {
+		return nil, err
+	}
+	last, err := readLastInjectors(path)
+	if err != nil {
+		return nil, err
+	}
+	if last[dir] == "" {
+		return nil, nil
+	}
+	return strings.Split(last[dir], ","), nil
+}
+
+// SetLastInjectors stores the fqdns as the injectors last used in dir.
+func SetLastInjectors(dir string, fqdns []string) error {
+	path, err := xdg.StateFile(lastInjectorsFile)
+	if err != nil {
+		return err
+	}
+	last, err := readLastInjectors(path)
+	if err != nil {
+		return err
+	}
+	last[dir] = strings.Join(fqdns, ",")
+	return writeLastInjectors(path, last)
+}
+
+func readLastInjectors(path string) (map[string]string, error) {
+	last := make(map[string]string)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return last, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &last); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return last, nil
+}
+
+// writeLastInjectors replaces the file atomically, so that concurrent runs of esi never read a partial file.
+func writeLastInjectors(path string, last map[string]string) error {
+	data, err := json.MarshalIndent(last, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), ".last-injectors-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // nolint:errcheck
+	if _, err := f.Write(data); err != nil {
+		f.Close() // nolint:errcheck
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,32 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/adrg/xdg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLastInjectors(t *testing.T) {
+	// reload after the env var was restored
+	t.Cleanup(xdg.Reload)
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	xdg.Reload()
+
+	last, err := LastInjectors("/project")
+	require.NoError(t, err)
+	assert.Empty(t, last)
+
+	require.NoError(t, SetLastInjectors("/project", []string{"dev.api"}))
+	require.NoError(t, SetLastInjectors("/other", []string{"prod.api", "cloud.deploy"}))
+	require.NoError(t, SetLastInjectors("/project", []string{"dev.worker"}))
+
+	last, err = LastInjectors("/project")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev.worker"}, last)
+
+	last, err = LastInjectors("/other")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"prod.api", "cloud.deploy"}, last)
+}
@@ -0,0 +1,30 @@
+//go:build !windows
+
+package tmpfile
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// lockFile acquires an exclusive lock on the file without blocking.
+// The lock is released by the kernel once the file is closed or the process dies.
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+// isAlive reports whether the process exists.
+func isAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// isLocked reports whether another process holds a lock on the file.
+func isLocked(f *os.File) (bool, error) {
+	err := lockFile(f)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return true, nil
+	}
+	return false, err
+}
@@ -0,0 +1,48 @@
+package tmpfile
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// removeAfterRead watches the file with inotify and removes it,
+// as soon as another process opened and closed it.
+func (t *TmpFile) removeAfterRead() error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return err
+	}
+	const mask = syscall.IN_CLOSE_WRITE | syscall.IN_CLOSE_NOWRITE | syscall.IN_DELETE_SELF
+	if _, err := syscall.InotifyAddWatch(fd, t.Path(), mask); err != nil {
+		syscall.Close(fd) // nolint:errcheck
+		return err
+	}
+
+	// the fd is non-blocking, so closing the file interrupts pending reads
+	watch := os.NewFile(uintptr(fd), "inotify")
+	t.stopWatch = func() { watch.Close() } // nolint:errcheck
+
+	go func() {
+		defer watch.Close()
+		buf := make([]byte, 4096)
+		for {
+			n, err := watch.Read(buf)
+			if err != nil {
+				return
+			}
+			for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+				ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off])) // #nosec G103
+				switch {
+				case ev.Mask&(syscall.IN_CLOSE_WRITE|syscall.IN_CLOSE_NOWRITE) != 0:
+					t.remove() // nolint:errcheck
+					return
+				case ev.Mask&(syscall.IN_DELETE_SELF|syscall.IN_IGNORED) != 0:
+					return
+				}
+				off += syscall.SizeofInotifyEvent + int(ev.Len)
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package tmpfile
+
+import "errors"
+
+// removeAfterRead is only supported on linux.
+func (t *TmpFile) removeAfterRead() error {
+	return errors.New("read once tmp files are only supported on linux")
+}
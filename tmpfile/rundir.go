@@ -0,0 +1,251 @@
+package tmpfile
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	lockFileName     = "esi.lock"
+	manifestFileName = "esi.manifest"
+	// lockGracePeriod protects directories of concurrent runs, which haven't created their lock file yet.
+	lockGracePeriod = time.Minute
+	// watchInterval is the interval in which Watch checks whether the processes of a run are gone.
+	watchInterval = time.Second
+)
+
+// RunDir is a private directory holding the tmp files of a single esi run.
+// The directory contains a lock file which is locked as long as esi is alive. The lock isn't inherited by the command,
+// because its background processes would keep the run dir alive forever. Instead, the lock file contains the PIDs
+// of esi and the commands, so that the run dir outlives esi as long as a command is running.
+type RunDir struct {
+	path string
+	lock *os.File
+}
+
+// manifestEntry describes a file outside of the run dir which belongs to the run.
+type manifestEntry struct {
+	Path   string `json:"path"`
+	Backup string `json:"backup,omitempty"`
+}
+
+// NewRunDir creates and locks a new private directory for the current run.
+// Directories of previous runs, whose owning process is dead, are removed.
+func NewRunDir(uid string) (*RunDir, error) {
+	if _, err := CleanupStale(uid); err != nil {
+		return nil, err
+	}
+
+	path, err := os.MkdirTemp(BaseDir(), runDirPrefix(uid))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create run dir: %w", err)
+	}
+
+	r := &RunDir{path: path}
+	r.lock, err = os.OpenFile(filepath.Join(path, lockFileName), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		r.Cleanup() // nolint:errcheck
+		return nil, fmt.Errorf("failed to create lock file: %w", err)
+	}
+	if err := lockFile(r.lock); err != nil {
+		r.Cleanup() // nolint:errcheck
+		return nil, fmt.Errorf("failed to lock run dir: %w", err)
+	}
+	if err := r.AddPID(os.Getpid()); err != nil {
+		r.Cleanup() // nolint:errcheck
+		return nil, fmt.Errorf("failed to write lock file: %w", err)
+	}
+	return r, nil
+}
+
+// BaseDir returns the directory containing the run dirs.
+// $XDG_RUNTIME_DIR is preferred, because it's usually a tmpfs only accessible by the user.
+func BaseDir() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		if fi, err := os.Stat(runtimeDir); err == nil && fi.IsDir() {
+			return runtimeDir
+		}
+	}
+	return os.TempDir()
+}
+
+func runDirPrefix(uid string) string {
+	return "esi-" + uid + "-"
+}
+
+// Path returns the path of the directory.
+func (r *RunDir) Path() string {
+	return r.path
+}
+
+// AddPID records a process of the run. The run dir isn't removed as long as the process is alive,
+// even if esi itself is gone.
+func (r *RunDir) AddPID(pid int) error {
+	_, err := r.lock.WriteString(strconv.Itoa(pid) + "\n")
+	return err
+}
+
+// Track records a file outside of the run dir, so that it can be removed
+// (and its backup restored) if the run doesn't cleanup properly.
+func (r *RunDir) Track(path, backup string) error {
+	f, err := os.OpenFile(filepath.Join(r.path, manifestFileName), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return json.NewEncoder(f).Encode(manifestEntry{Path: path, Backup: backup})
+}
+
+// Cleanup removes the directory including all files in it.
+// Tracked files outside of the directory must be cleaned up by their owner.
+func (r *RunDir) Cleanup() (string, error) {
+	if r.lock != nil {
+		r.lock.Close() // nolint:errcheck
+	}
+	return r.path, os.RemoveAll(r.path)
+}
+
+// CleanupStale removes all run dirs of the user, whose owning processes are dead.
+// It returns the paths of the removed directories.
+func CleanupStale(uid string) ([]string, error) {
+	base := BaseDir()
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, e := range entries {
+		if !e.IsDir() || !strings.HasPrefix(e.Name(), runDirPrefix(uid)) {
+			continue
+		}
+		path := filepath.Join(base, e.Name())
+		ok, err := PurgeIfStale(path)
+		if err != nil {
+			return removed, err
+		}
+		if ok {
+			removed = append(removed, path)
+		}
+	}
+	return removed, nil
+}
+
+// PurgeIfStale removes the run dir and all tracked files, if the owning processes are dead.
+func PurgeIfStale(path string) (bool, error) {
+	stale, err := isStale(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to check run dir %s: %w", path, err)
+	}
+	if !stale {
+		return false, nil
+	}
+	if err := purge(path); err != nil {
+		return false, fmt.Errorf("failed to cleanup old run dir: %w", err)
+	}
+	return true, nil
+}
+
+// Watch blocks until r is closed and removes the run dir once all processes are gone.
+// The writing end of r should only be held by esi, the other processes are recorded in the run dir.
+func Watch(path string, r io.Reader) error {
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return err
+	}
+	for {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			// esi cleaned up itself
+			return nil
+		}
+		ok, err := PurgeIfStale(path)
+		if err != nil || ok {
+			return err
+		}
+		time.Sleep(watchInterval)
+	}
+}
+
+// isStale reports whether the owning processes of the run dir are dead.
+// The lock is held by esi, the PIDs of the other processes are listed in the lock file.
+func isStale(path string) (bool, error) {
+	f, err := os.OpenFile(filepath.Join(path, lockFileName), os.O_RDWR, 0)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			return false, err
+		}
+		// the owner might still be creating the lock file
+		fi, err := os.Stat(path)
+		if err != nil {
+			return false, err
+		}
+		return time.Since(fi.ModTime()) > lockGracePeriod, nil
+	}
+	defer f.Close()
+
+	locked, err := isLocked(f)
+	if err != nil || locked {
+		return false, err
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		pid, err := strconv.Atoi(strings.TrimSpace(scanner.Text()))
+		if err == nil && pid > 0 && isAlive(pid) {
+			return false, nil
+		}
+	}
+	return true, scanner.Err()
+}
+
+// purge removes all tracked files and the run dir itself.
+func purge(path string) error {
+	f, err := os.Open(filepath.Join(path, manifestFileName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var e manifestEntry
+			if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+				return fmt.Errorf("invalid manifest: %w", err)
+			}
+			if err := restore(e); err != nil {
+				return err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	return os.RemoveAll(path)
+}
+
+// restore removes a tracked file and restores its backup.
+func restore(e manifestEntry) error {
+	if e.Backup == "" {
+		if err := os.Remove(e.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	if _, err := os.Lstat(e.Backup); err != nil {
+		// the backup was already restored
+		return nil
+	}
+	if err := os.Remove(e.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Rename(e.Backup, e.Path)
+}
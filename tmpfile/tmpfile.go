@@ -1,79 +1,282 @@
 package tmpfile
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
-	"text/template"
+	"sync"
 
 	"github.com/jon4hz/esi/config"
+	"github.com/jon4hz/esi/tmpl"
+)
+
+const (
+	defaultMode  = 0600
+	backupSuffix = ".esi-backup"
 )
 
 type TmpFile struct {
-	f *os.File
+	f    *os.File
+	path string
+	mode os.FileMode
+	// backup is the path of the original file, if a fixed path was overwritten.
+	backup string
+	// stopWatch stops watching a read once file.
+	stopWatch func()
+
+	removeOnce sync.Once
+	removeErr  error
+
+	// required to renew the file
+	injector *config.InjectorConfig
+	runDir   *RunDir
+	lookup   tmpl.LookupFunc
 }
 
-func New(injector *config.InjectorConfig, uid string) (*TmpFile, error) {
-	tmpDir := os.TempDir()
-	prefix := "esitmp-" + uid + "-"
-	if err := cleanupOldFolder(tmpDir, prefix); err != nil {
+// New renders the template of the injector config and writes it to a file.
+// Unless configured otherwise, the file is created in the given run dir.
+// Files outside of the run dir are tracked by it.
+func New(injector *config.InjectorConfig, runDir *RunDir, lookup tmpl.LookupFunc) (*TmpFile, error) {
+	// render the template first, so that we never write partial files
+	content, err := render(injector, lookup)
+	if err != nil {
 		return nil, err
 	}
 
-	f, err := os.CreateTemp(tmpDir, prefix)
+	mode, err := parseMode(injector.TmpFileMode)
 	if err != nil {
 		return nil, err
 	}
 
-	if suffix := injector.TmpFileSuffix; suffix != "" {
-		newName := f.Name() + suffix
-		if err := f.Close(); err != nil {
-			return nil, fmt.Errorf("failed to close tmpfile: %w", err)
+	var t *TmpFile
+	switch {
+	case injector.TmpFilePath != "":
+		path, err := expandHome(injector.TmpFilePath)
+		if err != nil {
+			return nil, err
+		}
+		t, err = createFixed(path, mode)
+		if err != nil {
+			return nil, err
+		}
+
+	case injector.TmpFileName != "":
+		dir, err := fileDir(injector.TmpFileDir, runDir.Path())
+		if err != nil {
+			return nil, err
 		}
-		if err := os.Rename(f.Name(), newName); err != nil {
-			return nil, fmt.Errorf("failed to rename tmpfile: %w", err)
+		f, err := create(filepath.Join(dir, injector.TmpFileName), mode)
+		if err != nil {
+			return nil, err
 		}
+		t = &TmpFile{f: f, path: f.Name()}
 
-		var err error
-		f, err = os.OpenFile(newName, os.O_RDWR|os.O_TRUNC|os.O_EXCL, 0600)
+	default:
+		dir, err := fileDir(injector.TmpFileDir, runDir.Path())
+		if err != nil {
+			return nil, err
+		}
+		f, err := os.CreateTemp(dir, "esitmp-*"+injector.TmpFileSuffix)
 		if err != nil {
+			return nil, err
+		}
+		t = &TmpFile{f: f, path: f.Name()}
+		if err := f.Chmod(mode); err != nil {
+			t.Cleanup() // nolint:errcheck
+			return nil, fmt.Errorf("failed to set file mode: %w", err)
+		}
+	}
+
+	t.mode = mode
+	t.injector, t.runDir, t.lookup = injector, runDir, lookup
+
+	if filepath.Dir(t.Path()) != runDir.Path() {
+		if err := runDir.Track(t.Path(), t.backup); err != nil {
+			t.Cleanup() // nolint:errcheck
+			return nil, fmt.Errorf("failed to track tmpfile: %w", err)
+		}
+	}
+
+	if _, err := t.f.Write(content); err != nil {
+		t.Cleanup() // nolint:errcheck
+		return nil, fmt.Errorf("failed to write tmpfile: %w", err)
+	}
+
+	if injector.TmpFileReadOnce {
+		// close the file first, so that we don't trigger the watch ourselves
+		if err := t.f.Close(); err != nil {
+			t.Cleanup() // nolint:errcheck
 			return nil, fmt.Errorf("failed to close tmpfile: %w", err)
 		}
+		if err := t.removeAfterRead(); err != nil {
+			t.Cleanup() // nolint:errcheck
+			return nil, fmt.Errorf("failed to watch tmpfile: %w", err)
+		}
 	}
 
-	tmpl, err := template.New(f.Name()).Parse(injector.TmpFileTmpl)
+	return t, nil
+}
+
+// render executes the template of the injector config.
+func render(injector *config.InjectorConfig, lookup tmpl.LookupFunc) ([]byte, error) {
+	text, err := injector.Template()
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := tmpl.New("tmpfile", lookup).Parse(text)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create template: %w", err)
 	}
-
-	if err := tmpl.Execute(f, injector); err != nil {
+	var content bytes.Buffer
+	if err := tpl.Execute(&content, injector); err != nil {
 		return nil, fmt.Errorf("failed to exec template: %w", err)
 	}
+	return content.Bytes(), nil
+}
 
-	return &TmpFile{f}, nil
+// fileDir returns the configured directory or falls back to the run dir.
+func fileDir(dir, runDir string) (string, error) {
+	if dir != "" {
+		return expandHome(dir)
+	}
+	return runDir, nil
 }
 
-func cleanupOldFolder(tmpdir, prefix string) error {
-	entries, err := os.ReadDir(tmpdir)
+// create creates a new file with the exact file mode. It fails if the file already exists.
+func create(path string, mode os.FileMode) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, mode)
 	if err != nil {
-		return err
+		return nil, fmt.Errorf("failed to create tmpfile: %w", err)
+	}
+	// make sure the umask doesn't change the mode
+	if err := f.Chmod(mode); err != nil {
+		f.Close()       // nolint:errcheck
+		os.Remove(path) // nolint:errcheck
+		return nil, fmt.Errorf("failed to set file mode: %w", err)
 	}
-	for _, e := range entries {
-		if strings.HasPrefix(e.Name(), prefix) {
-			if err := os.Remove(filepath.Join(tmpdir, e.Name())); err != nil {
-				return fmt.Errorf("failed to cleanup old dir: %w", err)
-			}
+	return f, nil
+}
+
+// createFixed creates a file at the given path. An existing file is moved aside
+// and restored during cleanup. If a backup of a previous run still exists, it's kept
+// and the file at path is considered a leftover of that run.
+func createFixed(path string, mode os.FileMode) (*TmpFile, error) {
+	backup := path + backupSuffix
+	if _, err := os.Lstat(backup); err == nil {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to remove leftover file: %w", err)
 		}
+	} else if _, err := os.Lstat(path); err == nil {
+		if err := os.Rename(path, backup); err != nil {
+			return nil, fmt.Errorf("failed to backup existing file: %w", err)
+		}
+	} else {
+		backup = ""
 	}
-	return nil
+
+	f, err := create(path, mode)
+	if err != nil {
+		if backup != "" {
+			os.Rename(backup, path) // nolint:errcheck
+		}
+		return nil, err
+	}
+	return &TmpFile{f: f, path: path, backup: backup}, nil
+}
+
+func parseMode(s string) (os.FileMode, error) {
+	if s == "" {
+		return defaultMode, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0, fmt.Errorf("invalid file mode: %q", s)
+	}
+	return os.FileMode(mode), nil
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to expand home dir: %w", err)
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~")), nil
 }
 
 func (t *TmpFile) Path() string {
-	return t.f.Name()
+	return t.path
+}
+
+// Renew renders the template again, e.g. after the secrets changed.
+// The content is replaced atomically, so that the command never reads a partial file.
+// Read once files were most likely removed already, so they are created again
+// and the returned file replaces t.
+func (t *TmpFile) Renew() (*TmpFile, error) {
+	if t.injector.TmpFileReadOnce {
+		if _, err := t.Cleanup(); err != nil {
+			return nil, fmt.Errorf("failed to remove tmpfile: %w", err)
+		}
+		return New(t.injector, t.runDir, t.lookup)
+	}
+
+	content, err := render(t.injector, t.lookup)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(filepath.Dir(t.path), ".esitmp-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tmpfile: %w", err)
+	}
+	if err := writeRenewed(f, content, t.mode, t.path); err != nil {
+		f.Close()           // nolint:errcheck
+		os.Remove(f.Name()) // nolint:errcheck
+		return nil, err
+	}
+	t.f.Close() // nolint:errcheck
+	t.f = f
+	return t, nil
 }
 
+// writeRenewed writes the content to f and moves it to path.
+func writeRenewed(f *os.File, content []byte, mode os.FileMode, path string) error {
+	if err := f.Chmod(mode); err != nil {
+		return fmt.Errorf("failed to set file mode: %w", err)
+	}
+	if _, err := f.Write(content); err != nil {
+		return fmt.Errorf("failed to write tmpfile: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("failed to replace tmpfile: %w", err)
+	}
+	return nil
+}
+
+// Cleanup removes the file and restores the original file, if one was replaced.
 func (t *TmpFile) Cleanup() (string, error) {
+	if t.stopWatch != nil {
+		t.stopWatch()
+	}
 	t.f.Close() // nolint:errcheck
-	return t.f.Name(), os.Remove(t.f.Name())
+	return t.path, t.remove()
+}
+
+// remove removes the file and restores the backup. It's safe to call remove multiple times.
+func (t *TmpFile) remove() error {
+	t.removeOnce.Do(func() {
+		if err := os.Remove(t.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			t.removeErr = err
+			return
+		}
+		if t.backup != "" {
+			t.removeErr = os.Rename(t.backup, t.path)
+		}
+	})
+	return t.removeErr
 }
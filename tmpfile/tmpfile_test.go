@@ -0,0 +1,290 @@
+package tmpfile
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jon4hz/esi/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newRunDir(t *testing.T) *RunDir {
+	t.Helper()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	r, err := NewRunDir("1000")
+	require.NoError(t, err)
+	t.Cleanup(func() { r.Cleanup() }) // nolint:errcheck
+	return r
+}
+
+func TestNewFixedPath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".netrc")
+	require.NoError(t, os.WriteFile(path, []byte("original"), 0644))
+
+	tf, err := New(&config.InjectorConfig{
+		TmpFile:     true,
+		TmpFilePath: path,
+		TmpFileTmpl: "machine example.com password s3cret",
+	}, newRunDir(t), nil)
+	require.NoError(t, err)
+	assert.Equal(t, path, tf.Path())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "machine example.com password s3cret", string(data))
+
+	fi, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	_, err = tf.Cleanup()
+	require.NoError(t, err)
+
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(data))
+	assert.NoFileExists(t, path+backupSuffix)
+}
+
+func TestNewNamed(t *testing.T) {
+	dir := t.TempDir()
+
+	tf, err := New(&config.InjectorConfig{
+		TmpFile:     true,
+		TmpFileDir:  dir,
+		TmpFileName: "ansible.cfg",
+		TmpFileMode: "0640",
+		TmpFileTmpl: "[defaults]",
+	}, newRunDir(t), nil)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "ansible.cfg"), tf.Path())
+
+	fi, err := os.Stat(tf.Path())
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), fi.Mode().Perm())
+
+	_, err = tf.Cleanup()
+	require.NoError(t, err)
+	assert.NoFileExists(t, tf.Path())
+}
+
+func TestNewRandom(t *testing.T) {
+	r := newRunDir(t)
+
+	tf, err := New(&config.InjectorConfig{
+		TmpFile:       true,
+		TmpFileSuffix: ".cfg",
+		TmpFileTmpl:   "[defaults]",
+	}, r, nil)
+	require.NoError(t, err)
+	assert.Equal(t, r.Path(), filepath.Dir(tf.Path()))
+	assert.True(t, strings.HasSuffix(tf.Path(), ".cfg"))
+
+	_, err = tf.Cleanup()
+	require.NoError(t, err)
+}
+
+func TestNewInvalidMode(t *testing.T) {
+	_, err := New(&config.InjectorConfig{
+		TmpFile:     true,
+		TmpFileDir:  t.TempDir(),
+		TmpFileMode: "rw-------",
+	}, newRunDir(t), nil)
+	assert.Error(t, err)
+}
+
+func TestRunDir(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	r1, err := NewRunDir("1000")
+	require.NoError(t, err)
+
+	// a dead run that left files behind
+	stale := filepath.Join(BaseDir(), runDirPrefix("1000")+"stale")
+	require.NoError(t, os.Mkdir(stale, 0700))
+	require.NoError(t, os.WriteFile(filepath.Join(stale, lockFileName), []byte("2147483647\n"), 0600))
+
+	r2, err := NewRunDir("1000")
+	require.NoError(t, err)
+
+	assert.DirExists(t, r1.Path())
+	assert.DirExists(t, r2.Path())
+	assert.NoDirExists(t, stale)
+
+	_, err = r1.Cleanup()
+	require.NoError(t, err)
+	_, err = r2.Cleanup()
+	require.NoError(t, err)
+	assert.NoDirExists(t, r1.Path())
+	assert.NoDirExists(t, r2.Path())
+}
+
+func TestPurgeRestoresBackup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".pgpass")
+	require.NoError(t, os.WriteFile(path, []byte("original"), 0600))
+
+	r := newRunDir(t)
+	_, err := New(&config.InjectorConfig{
+		TmpFile:     true,
+		TmpFilePath: path,
+		TmpFileTmpl: "secret",
+	}, r, nil)
+	require.NoError(t, err)
+
+	// still locked by us
+	ok, err := PurgeIfStale(r.Path())
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// simulate a crash: the lock is released and the owner is dead, but nothing was cleaned up
+	r.lock.Close()
+	require.NoError(t, os.WriteFile(filepath.Join(r.Path(), lockFileName), []byte("2147483647\n"), 0600))
+	ok, err = PurgeIfStale(r.Path())
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "original", string(data))
+	assert.NoDirExists(t, r.Path())
+}
+
+func TestRunDirOutlivesOwner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("run dirs are never stale on windows")
+	}
+
+	r := newRunDir(t)
+	cmd := exec.Command("sleep", "10")
+	require.NoError(t, cmd.Start())
+
+	// esi is gone (the test process is still alive, so it's not listed), but the command is still running
+	r.lock.Close()
+	lock := filepath.Join(r.Path(), lockFileName)
+	require.NoError(t, os.WriteFile(lock, []byte("2147483647\n"), 0600))
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	r.lock = f
+	require.NoError(t, r.AddPID(cmd.Process.Pid))
+	r.lock.Close()
+
+	ok, err := PurgeIfStale(r.Path())
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, cmd.Process.Kill())
+	_ = cmd.Wait()
+	ok, err = PurgeIfStale(r.Path())
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestWatchWaitsForCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("run dirs are never stale on windows")
+	}
+
+	r := newRunDir(t)
+	cmd := exec.Command("sleep", "10")
+	require.NoError(t, cmd.Start())
+	require.NoError(t, r.AddPID(cmd.Process.Pid))
+
+	pr, pw, err := os.Pipe()
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	go func() { done <- Watch(r.Path(), pr) }()
+
+	// esi is gone, but the command is still running
+	r.lock.Close()
+	require.NoError(t, os.WriteFile(filepath.Join(r.Path(), lockFileName), []byte(fmt.Sprintf("2147483647\n%d\n", cmd.Process.Pid)), 0600))
+	pw.Close()
+	select {
+	case err := <-done:
+		t.Fatalf("watch returned before the command exited: %v", err)
+	case <-time.After(2 * watchInterval):
+	}
+	assert.DirExists(t, r.Path())
+
+	require.NoError(t, cmd.Process.Kill())
+	_ = cmd.Wait()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * watchInterval):
+		t.Fatal("watch didn't return after the command exited")
+	}
+	assert.NoDirExists(t, r.Path())
+}
+
+func TestNewReadOnce(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("read once tmp files are only supported on linux")
+	}
+
+	tf, err := New(&config.InjectorConfig{
+		TmpFile:         true,
+		TmpFileReadOnce: true,
+		TmpFileTmpl:     "secret",
+	}, newRunDir(t), nil)
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(tf.Path())
+	require.NoError(t, err)
+	assert.Equal(t, "secret", string(data))
+
+	assert.Eventually(t, func() bool {
+		_, err := os.Stat(tf.Path())
+		return os.IsNotExist(err)
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = tf.Cleanup()
+	assert.NoError(t, err)
+}
+
+func TestRenew(t *testing.T) {
+	dir := t.TempDir()
+	secret := &config.Secret{ID: "token", Value: "old"}
+	lookup := func(id string) (string, bool) {
+		if id == secret.ID {
+			return secret.Value, true
+		}
+		return "", false
+	}
+
+	tf, err := New(&config.InjectorConfig{
+		TmpFile:     true,
+		TmpFileDir:  dir,
+		TmpFileName: "token",
+		TmpFileMode: "0640",
+		TmpFileTmpl: `{{ secret "token" }}`,
+	}, newRunDir(t), lookup)
+	require.NoError(t, err)
+
+	secret.Value = "new"
+	renewed, err := tf.Renew()
+	require.NoError(t, err)
+	assert.Equal(t, tf.Path(), renewed.Path())
+
+	data, err := os.ReadFile(renewed.Path())
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	info, err := os.Stat(renewed.Path())
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+
+	// no leftovers of the atomic write
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	_, err = renewed.Cleanup()
+	assert.NoError(t, err)
+	assert.NoFileExists(t, renewed.Path())
+}
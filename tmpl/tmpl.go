@@ -0,0 +1,152 @@
+package tmpl
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+)
+
+// SecretFunc is the name of the template function to reference secrets by their ID.
+const SecretFunc = "secret"
+
+// LookupFunc returns the value of the secret with the given ID and whether it exists.
+type LookupFunc func(id string) (string, bool)
+
+// New creates a new template with esi's function library.
+func New(name string, lookup LookupFunc) *template.Template {
+	return template.New(name).
+		Option("missingkey=error").
+		Funcs(FuncMap(lookup))
+}
+
+// FuncMap returns the functions available in templates.
+func FuncMap(lookup LookupFunc) template.FuncMap {
+	return template.FuncMap{
+		SecretFunc: func(id string) (string, error) {
+			if lookup == nil {
+				return "", fmt.Errorf("secret %q not found", id)
+			}
+			value, ok := lookup(id)
+			if !ok {
+				return "", fmt.Errorf("secret %q not found", id)
+			}
+			return value, nil
+		},
+		"env":      os.Getenv,
+		"default":  defaultValue,
+		"required": required,
+		"b64enc": func(s string) string {
+			return base64.StdEncoding.EncodeToString([]byte(s))
+		},
+		"toJson": func(v any) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+		"indent": func(spaces int, s string) string {
+			pad := strings.Repeat(" ", spaces)
+			return pad + strings.ReplaceAll(s, "\n", "\n"+pad)
+		},
+		"quote": func(v any) string {
+			return strconv.Quote(fmt.Sprint(v))
+		},
+	}
+}
+
+// SecretIDs returns the IDs of all secrets referenced with the secret function in the given template.
+// Only IDs passed as string literal can be detected.
+func SecretIDs(text string) ([]string, error) {
+	t, err := New("", nil).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, tt := range t.Templates() {
+		if tt.Tree != nil {
+			walk(tt.Tree.Root, &ids)
+		}
+	}
+	return ids, nil
+}
+
+func walk(node parse.Node, ids *[]string) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			walk(c, ids)
+		}
+	case *parse.ActionNode:
+		walk(n.Pipe, ids)
+	case *parse.IfNode:
+		walkBranch(&n.BranchNode, ids)
+	case *parse.RangeNode:
+		walkBranch(&n.BranchNode, ids)
+	case *parse.WithNode:
+		walkBranch(&n.BranchNode, ids)
+	case *parse.TemplateNode:
+		walk(n.Pipe, ids)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Cmds {
+			walk(c, ids)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) == 2 {
+			if ident, ok := n.Args[0].(*parse.IdentifierNode); ok && ident.Ident == SecretFunc {
+				if s, ok := n.Args[1].(*parse.StringNode); ok {
+					*ids = append(*ids, s.Text)
+				}
+			}
+		}
+		for _, a := range n.Args {
+			walk(a, ids)
+		}
+	}
+}
+
+func walkBranch(n *parse.BranchNode, ids *[]string) {
+	walk(n.Pipe, ids)
+	walk(n.List, ids)
+	walk(n.ElseList, ids)
+}
+
+// defaultValue returns def if the given value is empty.
+func defaultValue(def any, given ...any) any {
+	if len(given) == 0 || isEmpty(given[0]) {
+		return def
+	}
+	return given[0]
+}
+
+// required fails if the given value is empty.
+func required(msg string, v any) (any, error) {
+	if isEmpty(v) {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
+
+func isEmpty(v any) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return rv.IsZero()
+	}
+}
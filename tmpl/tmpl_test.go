@@ -0,0 +1,82 @@
+package tmpl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func render(t *testing.T, text string, data any) (string, error) {
+	t.Helper()
+	secrets := map[string]string{
+		"db-password": "s3cret",
+		"db-user":     "admin",
+	}
+	tmpl, err := New("test", func(id string) (string, bool) {
+		value, ok := secrets[id]
+		return value, ok
+	}).Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, data)
+	return b.String(), err
+}
+
+func TestFuncs(t *testing.T) {
+	t.Setenv("ESI_TEST_ENV", "from-env")
+
+	type testCase struct {
+		tmpl     string
+		expected string
+	}
+
+	testCases := []testCase{
+		{tmpl: `{{ secret "db-password" }}`, expected: "s3cret"},
+		{tmpl: `{{ env "ESI_TEST_ENV" }}`, expected: "from-env"},
+		{tmpl: `{{ env "ESI_TEST_UNSET" | default "fallback" }}`, expected: "fallback"},
+		{tmpl: `{{ secret "db-user" | default "fallback" }}`, expected: "admin"},
+		{tmpl: `{{ secret "db-user" | b64enc }}`, expected: "YWRtaW4="},
+		{tmpl: `{{ secret "db-user" | toJson }}`, expected: `"admin"`},
+		{tmpl: `{{ secret "db-user" | quote }}`, expected: `"admin"`},
+		{tmpl: `{{ "a\nb" | indent 2 }}`, expected: "  a\n  b"},
+		{tmpl: `{{ secret "db-user" | required "user missing" }}`, expected: "admin"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.tmpl, func(t *testing.T) {
+			actual, err := render(t, tc.tmpl, nil)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestFuncErrors(t *testing.T) {
+	for _, text := range []string{
+		`{{ secret "unknown" }}`,
+		`{{ env "ESI_TEST_UNSET" | required "env missing" }}`,
+		`{{ .Secrets.unknown }}`,
+	} {
+		t.Run(text, func(t *testing.T) {
+			_, err := render(t, text, map[string]any{"Secrets": map[string]string{}})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSecretIDs(t *testing.T) {
+	ids, err := SecretIDs(`
+{{ secret "a" }}
+{{ if true }}{{ secret "b" | quote }}{{ else }}{{ with (secret "c") }}{{ . }}{{ end }}{{ end }}
+{{ define "other" }}{{ secret "d" }}{{ end }}
+{{ env "NOT_A_SECRET" }}
+`)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"a", "b", "c", "d"}, ids)
+
+	_, err = SecretIDs(`{{ unknownFunc "a" }}`)
+	assert.Error(t, err)
+}
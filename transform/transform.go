@@ -0,0 +1,144 @@
+package transform
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jon4hz/esi/config"
+)
+
+// Apply applies all transforms in the given order to the value.
+func Apply(value string, transforms []*config.Transform) (string, error) {
+	var err error
+	for i, t := range transforms {
+		value, err = apply(value, t)
+		if err != nil {
+			return "", fmt.Errorf("transform %d failed: %w", i, err)
+		}
+	}
+	return value, nil
+}
+
+// apply applies the transform, which must set exactly one option.
+func apply(value string, t *config.Transform) (string, error) {
+	if options := t.Options(); len(options) != 1 {
+		return "", fmt.Errorf("transform must set exactly one option, got %d", len(options))
+	}
+	switch {
+	case t.JSONPath != "":
+		return JSONPath(value, t.JSONPath)
+	case t.Base64Decode:
+		return Base64Decode(value)
+	case t.Trim:
+		return strings.TrimSpace(value), nil
+	case t.Prefix != "":
+		return t.Prefix + value, nil
+	default:
+		return value + t.Suffix, nil
+	}
+}
+
+// Base64Decode decodes standard or url base64, with or without padding.
+func Base64Decode(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	for _, enc := range []*base64.Encoding{
+		base64.StdEncoding,
+		base64.RawStdEncoding,
+		base64.URLEncoding,
+		base64.RawURLEncoding,
+	} {
+		if b, err := enc.DecodeString(value); err == nil {
+			return string(b), nil
+		}
+	}
+	return "", errors.New("value is not base64 encoded")
+}
+
+// JSONPath extracts the value at path from the json document.
+// The path uses a jq like syntax, e.g. `.db.password` or `.hosts[0].name`.
+// Strings are returned as they are, everything else is returned as json.
+func JSONPath(doc, path string) (string, error) {
+	dec := json.NewDecoder(strings.NewReader(doc))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", fmt.Errorf("value is not valid json: %w", err)
+	}
+
+	steps, err := parsePath(path)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range steps {
+		switch node := v.(type) {
+		case map[string]any:
+			if s.isIndex {
+				return "", fmt.Errorf("cannot index object with [%d]", s.index)
+			}
+			var ok bool
+			if v, ok = node[s.key]; !ok {
+				return "", fmt.Errorf("key %q not found", s.key)
+			}
+		case []any:
+			if !s.isIndex {
+				return "", fmt.Errorf("cannot access key %q of array", s.key)
+			}
+			if s.index < 0 || s.index >= len(node) {
+				return "", fmt.Errorf("index %d out of range", s.index)
+			}
+			v = node[s.index]
+		default:
+			return "", fmt.Errorf("cannot traverse into %T", node)
+		}
+	}
+
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+type pathStep struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func parsePath(path string) ([]pathStep, error) {
+	if !strings.HasPrefix(path, ".") {
+		return nil, fmt.Errorf("invalid path %q: must start with a dot", path)
+	}
+
+	var steps []pathStep
+	for _, part := range strings.Split(strings.TrimPrefix(path, "."), ".") {
+		key, rest, _ := strings.Cut(part, "[")
+		if key != "" {
+			steps = append(steps, pathStep{key: key})
+		}
+		if rest == "" {
+			if key == "" && path != "." {
+				return nil, fmt.Errorf("invalid path %q: empty key", path)
+			}
+			continue
+		}
+		for _, idx := range strings.Split("["+rest, "[")[1:] {
+			i, err := strconv.Atoi(strings.TrimSuffix(idx, "]"))
+			if err != nil || !strings.HasSuffix(idx, "]") {
+				return nil, fmt.Errorf("invalid path %q: bad index %q", path, idx)
+			}
+			steps = append(steps, pathStep{index: i, isIndex: true})
+		}
+	}
+	return steps, nil
+}
@@ -0,0 +1,82 @@
+package transform
+
+import (
+	"testing"
+
+	"github.com/jon4hz/esi/config"
+	"github.com/stretchr/testify/assert"
+)
+
+const doc = `{
+	"db": {"user": "admin", "password": "s3cret", "port": 5432},
+	"hosts": [{"name": "a.example.com"}, {"name": "b.example.com"}],
+	"token": "  dG9rZW4=\n"
+}`
+
+func TestJSONPath(t *testing.T) {
+	type testCase struct {
+		path     string
+		expected string
+	}
+
+	testCases := []testCase{
+		{path: ".db.password", expected: "s3cret"},
+		{path: ".db.port", expected: "5432"},
+		{path: ".hosts[1].name", expected: "b.example.com"},
+		{path: ".hosts[0]", expected: `{"name":"a.example.com"}`},
+		{path: ".db", expected: `{"password":"s3cret","port":5432,"user":"admin"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			actual, err := JSONPath(doc, tc.path)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestJSONPathErrors(t *testing.T) {
+	for _, path := range []string{
+		"db.password",
+		".db.missing",
+		".hosts[2]",
+		".hosts.name",
+		".db[0]",
+		".hosts[x]",
+		".db..user",
+	} {
+		t.Run(path, func(t *testing.T) {
+			_, err := JSONPath(doc, path)
+			assert.Error(t, err)
+		})
+	}
+
+	_, err := JSONPath("no json", ".db")
+	assert.Error(t, err)
+}
+
+func TestApply(t *testing.T) {
+	actual, err := Apply(doc, []*config.Transform{
+		{JSONPath: ".token"},
+		{Base64Decode: true},
+		{Trim: true},
+		{Prefix: "Bearer "},
+		{Suffix: "!"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer token!", actual)
+
+	_, err = Apply("not base64!", []*config.Transform{{Base64Decode: true}})
+	assert.Error(t, err)
+
+	_, err = Apply(doc, []*config.Transform{{JSONPath: ".token", Base64Decode: true}})
+	assert.Error(t, err, "multiple options in one transform")
+
+	_, err = Apply(doc, []*config.Transform{{}})
+	assert.Error(t, err, "no option")
+
+	actual, err = Apply("  value\n", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "  value\n", actual)
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 
 	"github.com/charmbracelet/log"
+	"github.com/jon4hz/esi/config"
 	"gopkg.in/yaml.v3"
 )
 
@@ -15,7 +16,18 @@ const ESIWorkspaceFileName = ".esi-workspace.yml"
 var ErrFileNotFound = errors.New(fmt.Sprintf("file not found: %s", ESIWorkspaceFileName))
 
 type Workspace struct {
-	Injector string `yaml:"injector"`
+	Injector  string            `yaml:"injector"`
+	Injectors []string          `yaml:"injectors"`
+	Processes []*config.Process `yaml:"processes"`
+}
+
+// InjectorFQDNs returns the fqdns of all injectors set in the workspace file.
+func (w *Workspace) InjectorFQDNs() []string {
+	var fqdns []string
+	if w.Injector != "" {
+		fqdns = append(fqdns, w.Injector)
+	}
+	return append(fqdns, w.Injectors...)
 }
 
 func New() *Workspace {